@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stable player IDs and aliases. MPRIS bus names of browsers and some players
+// carry a per-process suffix (org.mpris.MediaPlayer2.chromium.instance12345),
+// so they can't be saved. Every player gets an id derived from its
+// DesktopEntry and Identity instead, or the alias of the first configured
+// player rule it matches; ?player= accepts either. Rules can also hide a
+// player entirely.
+
+// playerRule is one entry of the "players" section of the config file. Every
+// match field that is set must match; at least one is required.
+type playerRule struct {
+	Alias        string `json:"alias,omitempty"`
+	Identity     string `json:"identity,omitempty"`      // exact, case-insensitive
+	DesktopEntry string `json:"desktop_entry,omitempty"` // exact, case-insensitive
+	BusName      string `json:"bus_name,omitempty"`      // regex
+	Hidden       bool   `json:"hidden,omitempty"`
+
+	busRe *regexp.Regexp
+}
+
+var playerRules []playerRule
+
+var (
+	reBusInstance = regexp.MustCompile(`\.instance[_\d]+$`)
+	reIDUnsafe    = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func compilePlayerRules(rules []playerRule) ([]playerRule, error) {
+	out := make([]playerRule, 0, len(rules))
+	for i, r := range rules {
+		if r.Identity == "" && r.DesktopEntry == "" && r.BusName == "" {
+			return nil, fmt.Errorf("player rule %d: identity, desktop_entry or bus_name required", i)
+		}
+		if r.Alias == "" && !r.Hidden {
+			return nil, fmt.Errorf("player rule %d: alias or hidden required", i)
+		}
+		if r.Alias != "" && playerIDSlug(r.Alias) != r.Alias {
+			return nil, fmt.Errorf("player rule %d: alias %q must be lowercase letters, digits and dashes", i, r.Alias)
+		}
+		if r.BusName != "" {
+			re, err := regexp.Compile(r.BusName)
+			if err != nil {
+				return nil, fmt.Errorf("player rule %d: bus_name: %w", i, err)
+			}
+			r.busRe = re
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (r playerRule) matches(info playerInfo) bool {
+	if r.Identity != "" && !strings.EqualFold(r.Identity, info.Identity) {
+		return false
+	}
+	if r.DesktopEntry != "" && !strings.EqualFold(r.DesktopEntry, info.DesktopEntry) {
+		return false
+	}
+	if r.busRe != nil && !r.busRe.MatchString(info.BusName) {
+		return false
+	}
+	return true
+}
+
+func playerRuleFor(info playerInfo) (playerRule, bool) {
+	for _, r := range playerRules {
+		if r.matches(info) {
+			return r, true
+		}
+	}
+	return playerRule{}, false
+}
+
+func playerIDSlug(s string) string {
+	return strings.Trim(reIDUnsafe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// derivedPlayerID builds an id from DesktopEntry (or the bus name without its
+// instance suffix) plus Identity, e.g. "chromium", "spotify", "firefox".
+// Identity is left out when one already contains the other.
+func derivedPlayerID(info playerInfo) string {
+	base := strings.TrimSuffix(info.DesktopEntry, ".desktop")
+	if base == "" {
+		base = reBusInstance.ReplaceAllString(strings.TrimPrefix(info.BusName, "org.mpris.MediaPlayer2."), "")
+	}
+	base = playerIDSlug(base)
+	ident := playerIDSlug(info.Identity)
+	switch {
+	case base == "":
+		return ident
+	case ident == "", strings.Contains(base, ident), strings.Contains(ident, base):
+		return base
+	}
+	return base + "-" + ident
+}
+
+// firstSeen records when each bus name was first listed, so players that end
+// up with the same id are told apart by age rather than by ListNames order.
+// Names are forgotten a while after they stop being listed, so a player that
+// is skipped once (a slow reply) keeps its place.
+var firstSeen = struct {
+	sync.Mutex
+	first map[string]time.Time
+	last  map[string]time.Time
+}{first: make(map[string]time.Time), last: make(map[string]time.Time)}
+
+const firstSeenForget = time.Minute
+
+// seenOrder returns when each listed bus name was first seen.
+func seenOrder(players []playerInfo) map[string]time.Time {
+	firstSeen.Lock()
+	defer firstSeen.Unlock()
+	now := time.Now()
+	out := make(map[string]time.Time, len(players))
+	for _, p := range players {
+		at, ok := firstSeen.first[p.BusName]
+		if !ok {
+			at = now
+			firstSeen.first[p.BusName] = at
+		}
+		firstSeen.last[p.BusName] = now
+		out[p.BusName] = at
+	}
+	for name, last := range firstSeen.last {
+		if now.Sub(last) > firstSeenForget {
+			delete(firstSeen.first, name)
+			delete(firstSeen.last, name)
+		}
+	}
+	return out
+}
+
+// applyPlayerRules drops hidden players and assigns alias and id to the rest.
+// When several players end up with the same id (two Chromium windows, say),
+// the one remoted saw first keeps it and the others get "-2", "-3", … in the
+// order they appeared (bus name breaks ties). An id therefore stays with its
+// player while that player runs; it only shifts when an older one exits.
+func applyPlayerRules(players []playerInfo) []playerInfo {
+	out := players[:0]
+	for _, p := range players {
+		rule, ok := playerRuleFor(p)
+		if ok && rule.Hidden {
+			continue
+		}
+		p.ID = derivedPlayerID(p)
+		if ok && rule.Alias != "" {
+			p.Alias = rule.Alias
+			p.ID = rule.Alias
+		}
+		out = append(out, p)
+	}
+
+	seen := seenOrder(out)
+	byID := make(map[string][]int, len(out))
+	for i, p := range out {
+		byID[p.ID] = append(byID[p.ID], i)
+	}
+	for _, idx := range byID {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.Slice(idx, func(a, b int) bool {
+			pa, pb := out[idx[a]], out[idx[b]]
+			if ta, tb := seen[pa.BusName], seen[pb.BusName]; !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+			return pa.BusName < pb.BusName
+		})
+		for rank, i := range idx[1:] {
+			out[i].ID += "-" + strconv.Itoa(rank+2)
+		}
+	}
+	return out
+}
+
+// playerMatches reports whether name (from ?player= or a selector) refers to
+// p: its bus name, id, alias or identity.
+func playerMatches(p playerInfo, name string) bool {
+	if name == "" {
+		return false
+	}
+	return p.BusName == name || p.ID == name || strings.EqualFold(p.Alias, name) || strings.EqualFold(p.Identity, name)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// Artwork is resolved by walking an ordered chain of named providers; the
+// first one that returns art wins and its name is reported as art_hint.
+// Chains are chosen per player (bus name or identity), then per service (the
+// art_providers config, then the service rule's own chain), then the default.
+// New providers only need a registerArtProvider call.
+
+// artRequest carries everything a provider may need to look up art.
+type artRequest struct {
+	Info           playerInfo
+	Service        string
+	PlayerArtURL   string
+	PlayerArtProxy string
+	MPDClient      *mpd.Client // set only for MPD
+	MPDSongURI     string
+}
+
+// artResult is what a provider found. Exactly one of URL / Proxy is usually set.
+// Pending means a background lookup was scheduled; the chain stops there and
+// the art arrives with a later broadcast.
+type artResult struct {
+	URL     string
+	Proxy   string
+	Pending bool
+}
+
+type artProviderFunc func(ctx context.Context, req artRequest) (artResult, bool)
+
+var artProviders = struct {
+	mu    sync.RWMutex
+	store map[string]artProviderFunc
+}{store: make(map[string]artProviderFunc)}
+
+func registerArtProvider(name string, fn artProviderFunc) {
+	artProviders.mu.Lock()
+	defer artProviders.mu.Unlock()
+	artProviders.store[name] = fn
+}
+
+func lookupArtProvider(name string) artProviderFunc {
+	artProviders.mu.RLock()
+	defer artProviders.mu.RUnlock()
+	return artProviders.store[name]
+}
+
+func artProviderNames() []string {
+	artProviders.mu.RLock()
+	defer artProviders.mu.RUnlock()
+	names := make([]string, 0, len(artProviders.store))
+	for name := range artProviders.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	registerArtProvider("player", playerArtProvider)
+	registerArtProvider("tags", tagsArtProvider)
+	registerArtProvider("mpd", mpdArtProvider)
+	registerArtProvider("musicbrainz", musicBrainzArtProvider)
+	registerArtProvider("tmdb", tmdbArtProvider)
+	registerArtProvider("itunes", itunesArtProvider)
+	registerArtProvider("youtube", youtubeArtProvider)
+}
+
+// artChainConfig selects provider chains. Keys in Players match a bus name or
+// identity (case-insensitive); keys in Services match the detected service.
+type artChainConfig struct {
+	Default  []string            `json:"default,omitempty"`
+	Services map[string][]string `json:"services,omitempty"`
+	Players  map[string][]string `json:"players,omitempty"`
+}
+
+// defaultArtChains covers players: player art then local tags, and
+// readpicture+MusicBrainz for MPD. Per-service chains come from the service
+// rules (see services.go) unless overridden here.
+func defaultArtChains() artChainConfig {
+	return artChainConfig{
+		Default:  []string{"player", "tags"},
+		Services: map[string][]string{},
+		Players: map[string][]string{
+			"mpd": {"mpd", "musicbrainz"},
+		},
+	}
+}
+
+var artChains = defaultArtChains()
+
+// mergeArtChains overlays file config onto the defaults, per key.
+func mergeArtChains(base artChainConfig, override *artChainConfig) artChainConfig {
+	if override == nil {
+		return base
+	}
+	if override.Default != nil {
+		base.Default = override.Default
+	}
+	for k, v := range override.Services {
+		base.Services[strings.ToLower(k)] = v
+	}
+	for k, v := range override.Players {
+		base.Players[strings.ToLower(k)] = v
+	}
+	for _, chain := range append([][]string{base.Default}, mapValues(base.Services, base.Players)...) {
+		for _, name := range chain {
+			if lookupArtProvider(name) == nil {
+				log.Printf("warn: unknown art provider %q in config (known: %s)", name, strings.Join(artProviderNames(), ", "))
+			}
+		}
+	}
+	return base
+}
+
+func mapValues(maps ...map[string][]string) [][]string {
+	var out [][]string
+	for _, m := range maps {
+		for _, v := range m {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (c artChainConfig) chainFor(info playerInfo, service string) []string {
+	if chain, ok := c.Players[strings.ToLower(info.BusName)]; ok {
+		return chain
+	}
+	if chain, ok := c.Players[strings.ToLower(info.Identity)]; ok {
+		return chain
+	}
+	if service != "" {
+		if chain, ok := c.Services[service]; ok {
+			return chain
+		}
+		if rule, ok := serviceRuleByName(service); ok && rule.Art != nil {
+			// A TMDb-only chain (Crunchyroll) has nothing to offer without
+			// a key; keep the player's own art rather than none.
+			if tmdbKey == "" && len(rule.Art) == 1 && rule.Art[0] == "tmdb" {
+				return c.Default
+			}
+			return rule.Art
+		}
+	}
+	return c.Default
+}
+
+// resolveArt runs the configured chain for req and fills the art fields of info.
+// Art reported by the player itself is only used if "player" is in the chain.
+func resolveArt(ctx context.Context, info *playerInfo, req artRequest) {
+	req.PlayerArtURL, req.PlayerArtProxy = info.ArtURL, info.ArtURLProxy
+	if req.Service == "" {
+		req.Service = info.Service
+	}
+	req.Info = *info
+	info.ArtURL, info.ArtURLProxy, info.ArtHint = "", "", ""
+
+	chain := artChains.chainFor(*info, req.Service)
+	for _, name := range chain {
+		if name == "tmdb" {
+			applyTMDbDetails(info)
+			break
+		}
+	}
+
+	for _, name := range chain {
+		provider := lookupArtProvider(name)
+		if provider == nil {
+			continue
+		}
+		res, ok := provider(ctx, req)
+		if ok {
+			info.ArtURL, info.ArtURLProxy, info.ArtHint = res.URL, res.Proxy, name
+			return
+		}
+		if res.Pending {
+			return
+		}
+	}
+}
+
+// ── Providers ────────────────────────────────────────────────────────────────
+
+func playerArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	if req.PlayerArtURL == "" && req.PlayerArtProxy == "" {
+		return artResult{}, false
+	}
+	return artResult{URL: req.PlayerArtURL, Proxy: req.PlayerArtProxy}, true
+}
+
+func tagsArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	if len(musicRoots) == 0 {
+		return artResult{}, false
+	}
+	if proxy := localTagArtProxy(req.Info.URL); proxy != "" {
+		return artResult{Proxy: proxy}, true
+	}
+	return artResult{}, false
+}
+
+func mpdArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	if req.MPDClient == nil || req.MPDSongURI == "" {
+		return artResult{}, false
+	}
+	data, err := req.MPDClient.ReadPicture(req.MPDSongURI)
+	if err != nil || len(data) == 0 {
+		return artResult{}, false
+	}
+	proxy, err := cacheMPDPicture(data, req.MPDSongURI)
+	if err != nil {
+		log.Printf("warn: mpd cache picture: %v", err)
+		return artResult{}, false
+	}
+	return artResult{Proxy: proxy}, true
+}
+
+func musicBrainzArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	artist, album := req.Info.Artist, req.Info.Album
+	if artist == "" || album == "" || offlineMode() {
+		return artResult{}, false
+	}
+	key := musicBrainzCacheKey(artist, album)
+	if e, ok := mbArtCache.Get(key); ok {
+		return artResult{URL: e.URL}, e.URL != ""
+	}
+	enrichment.Go("musicbrainz:"+key, func(ctx context.Context) {
+		musicBrainzArtURL(ctx, artist, album)
+	})
+	return artResult{Pending: true}, false
+}
+
+// tmdbArtProvider searches TMDb by title (see tmdbQueryTitle). Service rules
+// have already stripped site suffixes; if no show name is left there is
+// nothing useful to search for.
+func tmdbArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	if tmdbKey == "" || offlineMode() {
+		return artResult{}, false
+	}
+	title := strings.TrimSpace(tmdbQueryTitle(req.Info))
+	if title == "" {
+		return artResult{}, false
+	}
+	if e, ok := tmdbCache.Get(strings.ToLower(title)); ok {
+		poster := tmdbPosterURL(e)
+		return artResult{URL: poster}, poster != ""
+	}
+	enrichment.Go("tmdb:"+strings.ToLower(title), func(ctx context.Context) {
+		tmdbLookup(ctx, title)
+	})
+	return artResult{Pending: true}, false
+}
+
+func youtubeArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	if thumb := youtubeThumbURL(req.Info.URL); thumb != "" {
+		return artResult{URL: thumb}, true
+	}
+	return artResult{}, false
+}
+
+// youtubeThumbURL returns the hqdefault thumbnail for a youtube.com/youtu.be
+// video URL, or "" for anything else.
+func youtubeThumbURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	var id string
+	switch host {
+	case "youtube.com", "www.youtube.com", "m.youtube.com", "music.youtube.com":
+		id = u.Query().Get("v")
+	case "youtu.be":
+		id = strings.TrimPrefix(u.Path, "/")
+	}
+	if id == "" || strings.ContainsAny(id, "/?#") {
+		return ""
+	}
+	return "https://i.ytimg.com/vi/" + url.PathEscape(id) + "/hqdefault.jpg"
+}
+
+var itunesArtCache = newLookupCache(12 * time.Hour)
+
+func itunesCacheKey(artist, album, title string) string {
+	return strings.ToLower(artist + "|" + album + "|" + title)
+}
+
+func itunesArtProvider(ctx context.Context, req artRequest) (artResult, bool) {
+	artist, album, title := req.Info.Artist, req.Info.Album, req.Info.Title
+	if artist == "" || (album == "" && title == "") || offlineMode() {
+		return artResult{}, false
+	}
+	key := itunesCacheKey(artist, album, title)
+	if e, ok := itunesArtCache.Get(key); ok {
+		return artResult{URL: e.URL}, e.URL != ""
+	}
+	enrichment.Go("itunes:"+key, func(ctx context.Context) {
+		itunesArtURL(ctx, artist, album, title)
+	})
+	return artResult{Pending: true}, false
+}
+
+// itunesArtURL searches the iTunes Search API (no key required) by album, or
+// by song title when the album is unknown. Results are cached for 12 hours
+// (failed requests for lookupRetryAfter).
+func itunesArtURL(ctx context.Context, artist, album, title string) string {
+	key := itunesCacheKey(artist, album, title)
+	if e, ok := itunesArtCache.Get(key); ok {
+		return e.URL
+	}
+
+	artURL, err := fetchITunesArt(ctx, artist, album, title)
+	if err != nil {
+		log.Printf("warn: itunes lookup %q/%q: %v", artist, album, err)
+	}
+	itunesArtCache.Set(key, tmdbCacheEntry{URL: artURL, StoredAt: time.Now(), Errored: artURL == "", Transient: err != nil})
+	return artURL
+}
+
+func fetchITunesArt(ctx context.Context, artist, album, title string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	term, entity := artist+" "+album, "album"
+	if album == "" {
+		term, entity = artist+" "+title, "song"
+	}
+	q := url.Values{}
+	q.Set("term", term)
+	q.Set("media", "music")
+	q.Set("entity", entity)
+	q.Set("limit", "5")
+	resp, err := outboundRequest(ctx, http.MethodGet, outbound.Endpoints.ITunes+"/search?"+q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("itunes status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			ArtistName     string `json:"artistName"`
+			CollectionName string `json:"collectionName"`
+			ArtworkURL100  string `json:"artworkUrl100"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	needle := normalizeTitle(artist)
+	for _, r := range result.Results {
+		if r.ArtworkURL100 == "" || normalizeTitle(r.ArtistName) != needle {
+			continue
+		}
+		// The CDN serves any size by rewriting the dimensions in the path.
+		return strings.Replace(r.ArtworkURL100, "100x100bb", "600x600bb", 1), nil
+	}
+	return "", nil
+}
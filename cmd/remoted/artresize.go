@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+)
+
+const (
+	artMinWidth     = 32
+	artMaxWidth     = 2048
+	artWidthStep    = 32
+	artJPEGQuality  = 85
+	artMaxPixels    = 64 << 20  // refuse to decode anything larger than ~64 megapixels
+	artJPEGMinBytes = 256 << 10 // smaller PNGs aren't worth a lossy re-encode
+	artCacheControl = "private, max-age=604800"
+)
+
+// artVariantDir holds resized / re-encoded copies of cached artwork.
+func artVariantDir() string {
+	return filepath.Join(artCacheDir, "variants")
+}
+
+// parseArtWidth reads ?w= and snaps it to a multiple of artWidthStep so the
+// number of cached variants per cover stays bounded. Returns 0 when absent or invalid.
+func parseArtWidth(r *http.Request) int {
+	raw := strings.TrimSpace(r.URL.Query().Get("w"))
+	if raw == "" {
+		return 0
+	}
+	w, err := strconv.Atoi(raw)
+	if err != nil || w <= 0 {
+		return 0
+	}
+	if w < artMinWidth {
+		w = artMinWidth
+	}
+	if w > artMaxWidth {
+		w = artMaxWidth
+	}
+	if rem := w % artWidthStep; rem != 0 {
+		w += artWidthStep - rem
+	}
+	return w
+}
+
+// acceptsJPEG reports whether the Accept header lists image/jpeg explicitly.
+// Wildcards (image/*, */*) and a missing header don't count: a client that
+// didn't ask for JPEG gets the original PNG.
+func acceptsJPEG(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		fields := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mt != "image/jpeg" {
+			continue
+		}
+		rejected := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || param == "q=0.0" || param == "q=0.00" || param == "q=0.000" {
+				rejected = true
+			}
+		}
+		if !rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// artETag builds a strong validator for a file on disk. Cache entries are
+// written once via rename, so name+size+mtime identifies the exact bytes.
+func artETag(path string, stat os.FileInfo) string {
+	h := sha1.New()
+	_, _ = io.WriteString(h, filepath.Base(path))
+	_, _ = io.WriteString(h, strconv.FormatInt(stat.Size(), 10))
+	_, _ = io.WriteString(h, strconv.FormatInt(stat.ModTime().UnixNano(), 10))
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+}
+
+// artVariant returns the path of a resized and/or JPEG re-encoded copy of src,
+// creating it on first use. width == 0 keeps the original dimensions. When no
+// transformation applies (image already small enough and in an acceptable
+// format, or not decodable) src is returned unchanged.
+func artVariant(src string, width int, wantJPEG bool) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	stat, statErr := f.Stat()
+	f.Close()
+	if err == nil {
+		err = statErr
+	}
+	if err != nil {
+		// Formats we can't decode (webp, svg, ...) are served as-is.
+		return src, nil
+	}
+	if cfg.Width*cfg.Height > artMaxPixels {
+		return src, nil
+	}
+
+	resize := width > 0 && cfg.Width > width
+	reencode := wantJPEG && format == "png" && stat.Size() >= artJPEGMinBytes
+	if !resize && !reencode {
+		return src, nil
+	}
+
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	suffix := "full"
+	if resize {
+		suffix = "w" + strconv.Itoa(width)
+	}
+	ext := ".png"
+	if reencode || format == "jpeg" {
+		ext = ".jpg"
+	}
+	dest := filepath.Join(artVariantDir(), base+"-"+suffix+ext)
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	f, err = os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return src, nil
+	}
+
+	// JPEG has no alpha channel; keep translucent art in its original encoding.
+	if reencode && !isOpaque(img) {
+		if !resize {
+			return src, nil
+		}
+		ext = ".png"
+		dest = filepath.Join(artVariantDir(), base+"-"+suffix+ext)
+		if _, err := os.Stat(dest); err == nil {
+			return dest, nil
+		}
+	}
+
+	if resize {
+		img = scaleToWidth(img, width)
+	}
+
+	if err := os.MkdirAll(artVariantDir(), 0o755); err != nil {
+		return "", err
+	}
+	tmp := dest + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if ext == ".jpg" {
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: artJPEGQuality})
+	} else {
+		err = png.Encode(out, img)
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return dest, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// toRGBA converts any image to *image.RGBA anchored at (0,0). draw.Draw has
+// fast paths for the YCbCr/NRGBA images produced by the stdlib decoders.
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && b.Min == (image.Point{}) {
+		return rgba
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// scaleToWidth downsamples img to the given width (keeping aspect ratio) using
+// an area-averaging box filter. Only shrinks; larger targets return img as-is.
+func scaleToWidth(img image.Image, width int) image.Image {
+	src := toRGBA(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if width <= 0 || width >= sw || sh == 0 {
+		return src
+	}
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * sh / height
+		y1 := (dy + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * sw / width
+			x1 := (dx + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+					n++
+				}
+			}
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Local-file artwork: when an MPRIS player exposes xesam:url=file://... but no
+// mpris:artUrl, pull the cover out of the file's tags (ID3v2 APIC, FLAC
+// PICTURE, Ogg METADATA_BLOCK_PICTURE, MP4 covr) or a sidecar image next to it.
+
+const maxEmbeddedArt = 16 << 20
+
+var errNoEmbeddedArt = errors.New("no embedded art")
+
+// sidecarArtNames are checked case-insensitively in the track's directory.
+var sidecarArtNames = []string{
+	"cover.jpg", "cover.jpeg", "cover.png",
+	"folder.jpg", "folder.jpeg", "folder.png",
+	"front.jpg", "front.png",
+	"album.jpg", "album.png",
+	"albumart.jpg",
+}
+
+// tagArtCache remembers the cache name (or "" for nothing found) per file
+// path+mtime+size so tags are parsed once per track, not on every broadcast.
+var tagArtCache = struct {
+	mu    sync.RWMutex
+	store map[string]string
+}{store: make(map[string]string)}
+
+// localTagArtProxy returns an /art/ proxy path for a file:// track URL, or ""
+// when the file is outside musicRoots or has no usable artwork.
+func localTagArtProxy(trackURL string) string {
+	u, err := url.Parse(trackURL)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	srcPath, ok := pathWithinRoots(filepath.Clean(u.Path), musicRoots)
+	if !ok {
+		return ""
+	}
+	stat, err := os.Stat(srcPath)
+	if err != nil || stat.IsDir() {
+		return ""
+	}
+
+	key := fmt.Sprintf("%s|%d|%d", srcPath, stat.ModTime().UnixNano(), stat.Size())
+	tagArtCache.mu.RLock()
+	cacheName, ok := tagArtCache.store[key]
+	tagArtCache.mu.RUnlock()
+	if ok {
+		if cacheName == "" {
+			return ""
+		}
+		return "/art/" + cacheName
+	}
+
+	cacheName = extractLocalArt(srcPath, key)
+
+	tagArtCache.mu.Lock()
+	tagArtCache.store[key] = cacheName
+	tagArtCache.mu.Unlock()
+	if cacheName == "" {
+		return ""
+	}
+	return "/art/" + cacheName
+}
+
+func extractLocalArt(srcPath, key string) string {
+	data, err := readEmbeddedArt(srcPath)
+	if err == nil && len(data) > 0 {
+		cacheName, err := cacheArtBytes("tags:"+key, data)
+		if err == nil {
+			return cacheName
+		}
+		log.Printf("warn: cache embedded art for %s: %v", srcPath, err)
+	} else if err != nil && !errors.Is(err, errNoEmbeddedArt) {
+		log.Printf("warn: read embedded art from %s: %v", srcPath, err)
+	}
+
+	sidecar := findSidecarArt(filepath.Dir(srcPath))
+	if sidecar == "" {
+		return ""
+	}
+	sidecar, ok := pathWithinRoots(sidecar, musicRoots)
+	if !ok {
+		return ""
+	}
+	cacheName, err := cacheArt(sidecar)
+	if err != nil {
+		log.Printf("warn: cache sidecar art %s: %v", sidecar, err)
+		return ""
+	}
+	return cacheName
+}
+
+func findSidecarArt(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	byName := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		byName[strings.ToLower(e.Name())] = e.Name()
+	}
+	for _, want := range sidecarArtNames {
+		if name, ok := byName[want]; ok {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// readEmbeddedArt sniffs the container and returns the front cover (or the
+// first picture) embedded in it.
+func readEmbeddedArt(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return embeddedArt(f, stat.Size())
+}
+
+// embeddedArt is readEmbeddedArt on an already open file of the given size.
+func embeddedArt(r interface {
+	io.ReadSeeker
+	io.ReaderAt
+}, size int64) ([]byte, error) {
+	var magic [12]byte
+	n, _ := io.ReadFull(r, magic[:])
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case n >= 3 && string(magic[:3]) == "ID3":
+		return readID3Picture(r)
+	case n >= 4 && string(magic[:4]) == "fLaC":
+		return readFLACPicture(r)
+	case n >= 4 && string(magic[:4]) == "OggS":
+		return readOggPicture(r)
+	case n >= 8 && string(magic[4:8]) == "ftyp":
+		return readMP4Picture(r, size)
+	}
+	return nil, errNoEmbeddedArt
+}
+
+// ── ID3v2 ────────────────────────────────────────────────────────────────────
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// unsynchronise reverses ID3 unsynchronisation (0xFF 0x00 -> 0xFF).
+func unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+func readID3Picture(r io.Reader) ([]byte, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	version, flags := hdr[3], hdr[5]
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported ID3v2.%d", version)
+	}
+	size := syncsafe(hdr[6:10])
+	if size > maxEmbeddedArt*2 {
+		return nil, fmt.Errorf("ID3 tag too large (%d bytes)", size)
+	}
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return nil, err
+	}
+	if version < 4 && flags&0x80 != 0 {
+		tag = unsynchronise(tag)
+	}
+	if flags&0x40 != 0 && version >= 3 && len(tag) >= 4 {
+		var ext int
+		if version == 4 {
+			ext = syncsafe(tag[:4])
+		} else {
+			ext = int(binary.BigEndian.Uint32(tag[:4])) + 4
+		}
+		if ext > len(tag) {
+			return nil, errNoEmbeddedArt
+		}
+		tag = tag[ext:]
+	}
+
+	var fallback []byte
+	for len(tag) > 0 {
+		var id string
+		var body []byte
+		var frameFlags byte
+		if version == 2 {
+			if len(tag) < 6 || tag[0] == 0 {
+				break
+			}
+			id = string(tag[:3])
+			fsize := int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+			if 6+fsize > len(tag) {
+				break
+			}
+			body, tag = tag[6:6+fsize], tag[6+fsize:]
+		} else {
+			if len(tag) < 10 || tag[0] == 0 {
+				break
+			}
+			id = string(tag[:4])
+			fsize := int(binary.BigEndian.Uint32(tag[4:8]))
+			if version == 4 {
+				fsize = syncsafe(tag[4:8])
+			}
+			frameFlags = tag[9]
+			if fsize < 0 || 10+fsize > len(tag) {
+				break
+			}
+			body, tag = tag[10:10+fsize], tag[10+fsize:]
+		}
+
+		if id != "APIC" && id != "PIC" {
+			continue
+		}
+		if version == 4 {
+			if frameFlags&0x0c != 0 { // compressed or encrypted
+				continue
+			}
+			if frameFlags&0x01 != 0 && len(body) >= 4 { // data length indicator
+				body = body[4:]
+			}
+			if frameFlags&0x02 != 0 {
+				body = unsynchronise(body)
+			}
+		} else if version == 3 && frameFlags&0xc0 != 0 {
+			continue
+		}
+		picType, data := parseAPIC(body, version == 2)
+		if len(data) == 0 {
+			continue
+		}
+		if picType == 3 {
+			return data, nil
+		}
+		if fallback == nil {
+			fallback = data
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, errNoEmbeddedArt
+}
+
+// parseAPIC splits an APIC (v2.3/2.4) or PIC (v2.2) frame body into its
+// picture type and image bytes.
+func parseAPIC(body []byte, v22 bool) (byte, []byte) {
+	if len(body) < 2 {
+		return 0, nil
+	}
+	enc := body[0]
+	rest := body[1:]
+	if v22 {
+		if len(rest) < 3 {
+			return 0, nil
+		}
+		rest = rest[3:] // 3-char image format
+	} else {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return 0, nil
+		}
+		rest = rest[i+1:] // MIME type, latin-1, NUL-terminated
+	}
+	if len(rest) < 1 {
+		return 0, nil
+	}
+	picType := rest[0]
+	rest = rest[1:]
+	// Description: single NUL for latin-1/UTF-8, aligned double NUL for UTF-16.
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(rest); i += 2 {
+			if rest[i] == 0 && rest[i+1] == 0 {
+				return picType, rest[i+2:]
+			}
+		}
+		return 0, nil
+	}
+	i := bytes.IndexByte(rest, 0)
+	if i < 0 {
+		return 0, nil
+	}
+	return picType, rest[i+1:]
+}
+
+// ── FLAC / Vorbis comments ───────────────────────────────────────────────────
+
+func readFLACPicture(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(4); err != nil {
+		return nil, err
+	}
+	var fallback []byte
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, err
+		}
+		last := hdr[0]&0x80 != 0
+		blockType := hdr[0] & 0x7f
+		length := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+		if blockType == 6 && length <= maxEmbeddedArt {
+			block := make([]byte, length)
+			if _, err := io.ReadFull(br, block); err != nil {
+				return nil, err
+			}
+			picType, data := parseFLACPictureBlock(block)
+			if picType == 3 && len(data) > 0 {
+				return data, nil
+			}
+			if fallback == nil && len(data) > 0 {
+				fallback = data
+			}
+		} else if _, err := br.Discard(length); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, errNoEmbeddedArt
+}
+
+// parseFLACPictureBlock decodes a FLAC METADATA_BLOCK_PICTURE body. The same
+// structure is base64-encoded in Vorbis/Opus comments.
+func parseFLACPictureBlock(b []byte) (uint32, []byte) {
+	next := func(n int) []byte {
+		if n < 0 || len(b) < n {
+			b = nil
+			return nil
+		}
+		out := b[:n]
+		b = b[n:]
+		return out
+	}
+	u32 := func() int {
+		v := next(4)
+		if v == nil {
+			return -1
+		}
+		return int(binary.BigEndian.Uint32(v))
+	}
+	picType := u32()
+	next(u32()) // MIME type
+	next(u32()) // description
+	next(16)    // width, height, depth, colors
+	data := next(u32())
+	if picType < 0 || data == nil {
+		return 0, nil
+	}
+	return uint32(picType), data
+}
+
+// pictureFromVorbisComments scans a Vorbis comment block (after the packet
+// magic) for METADATA_BLOCK_PICTURE entries.
+func pictureFromVorbisComments(b []byte) []byte {
+	le32 := func() int {
+		if len(b) < 4 {
+			return -1
+		}
+		v := int(binary.LittleEndian.Uint32(b[:4]))
+		b = b[4:]
+		return v
+	}
+	vendor := le32()
+	if vendor < 0 || vendor > len(b) {
+		return nil
+	}
+	b = b[vendor:]
+	count := le32()
+	const key = "metadata_block_picture="
+	var fallback []byte
+	for i := 0; i < count; i++ {
+		n := le32()
+		if n < 0 || n > len(b) {
+			break
+		}
+		comment := b[:n]
+		b = b[n:]
+		if len(comment) <= len(key) || strings.ToLower(string(comment[:len(key)])) != key {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(string(comment[len(key):]))
+		if err != nil {
+			continue
+		}
+		picType, data := parseFLACPictureBlock(raw)
+		if picType == 3 && len(data) > 0 {
+			return data
+		}
+		if fallback == nil && len(data) > 0 {
+			fallback = data
+		}
+	}
+	return fallback
+}
+
+// ── Ogg (Vorbis, Opus, FLAC) ─────────────────────────────────────────────────
+
+// readOggPicture reassembles the first few packets of the first logical stream
+// and looks for the comment header.
+func readOggPicture(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var serial uint32
+	var packet []byte
+	packets := 0
+	total := 0
+	for first := true; ; first = false {
+		var hdr [27]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, errNoEmbeddedArt
+		}
+		if string(hdr[:4]) != "OggS" {
+			return nil, fmt.Errorf("bad ogg page")
+		}
+		pageSerial := binary.LittleEndian.Uint32(hdr[14:18])
+		if first {
+			serial = pageSerial
+		}
+		segTable := make([]byte, hdr[26])
+		if _, err := io.ReadFull(br, segTable); err != nil {
+			return nil, err
+		}
+		for _, seg := range segTable {
+			buf := make([]byte, seg)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return nil, err
+			}
+			if pageSerial != serial {
+				continue
+			}
+			packet = append(packet, buf...)
+			total += int(seg)
+			if total > maxEmbeddedArt*2 {
+				return nil, fmt.Errorf("ogg comment header too large")
+			}
+			if seg == 255 {
+				continue
+			}
+			packets++
+			if data, done := oggCommentPicture(packet, packets); done {
+				if len(data) == 0 {
+					return nil, errNoEmbeddedArt
+				}
+				return data, nil
+			}
+			packet = nil
+			if packets >= 8 {
+				return nil, errNoEmbeddedArt
+			}
+		}
+	}
+}
+
+// oggCommentPicture inspects one complete packet. done is true once the
+// comment header has been seen (whether or not it held a picture).
+func oggCommentPicture(p []byte, index int) (data []byte, done bool) {
+	switch {
+	case len(p) >= 7 && p[0] == 0x03 && string(p[1:7]) == "vorbis":
+		return pictureFromVorbisComments(p[7:]), true
+	case len(p) >= 8 && string(p[:8]) == "OpusTags":
+		return pictureFromVorbisComments(p[8:]), true
+	case index > 1 && len(p) >= 4 && p[0]&0x7f == 6:
+		// Ogg FLAC: header packets after the first are raw metadata blocks.
+		if _, d := parseFLACPictureBlock(p[4:]); len(d) > 0 {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// ── MP4 / M4A ────────────────────────────────────────────────────────────────
+
+func readMP4Picture(f io.ReaderAt, fileSize int64) ([]byte, error) {
+	path := []string{"moov", "udta", "meta", "ilst", "covr", "data"}
+	start, end := int64(0), fileSize
+	for depth, want := range path {
+		found := false
+		for pos := start; pos+8 <= end; {
+			var hdr [16]byte
+			if _, err := f.ReadAt(hdr[:8], pos); err != nil {
+				return nil, err
+			}
+			size := int64(binary.BigEndian.Uint32(hdr[:4]))
+			kind := string(hdr[4:8])
+			headerLen := int64(8)
+			switch size {
+			case 1:
+				if _, err := f.ReadAt(hdr[8:16], pos+8); err != nil {
+					return nil, err
+				}
+				size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+				headerLen = 16
+			case 0:
+				size = end - pos
+			}
+			if size < headerLen || size > end-pos {
+				return nil, errNoEmbeddedArt
+			}
+			if kind != want {
+				pos += size
+				continue
+			}
+			start, end = pos+headerLen, pos+size
+			if kind == "meta" {
+				// ISO 'meta' is a full box (4 bytes version/flags); QuickTime's is not.
+				var peek [8]byte
+				if _, err := f.ReadAt(peek[:], start); err == nil && string(peek[4:8]) != "hdlr" {
+					start += 4
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return nil, errNoEmbeddedArt
+		}
+		if depth == len(path)-1 {
+			// 'data' payload: 4 bytes type indicator, 4 bytes locale, then the image.
+			n := end - start - 8
+			if n <= 0 || n > maxEmbeddedArt {
+				return nil, errNoEmbeddedArt
+			}
+			data := make([]byte, n)
+			if _, err := f.ReadAt(data, start+8); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+	}
+	return nil, errNoEmbeddedArt
+}
+
+// ── Path allow-lists ─────────────────────────────────────────────────────────
+
+// pathWithinRoots resolves symlinks in p and reports whether the result is one
+// of roots or lies beneath one of them. Callers must open the returned path,
+// not p: p's links could be swapped after the check.
+func pathWithinRoots(p string, roots []string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", false
+	}
+	for _, root := range roots {
+		r, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(r, resolved)
+		if err != nil || filepath.IsAbs(rel) {
+			continue
+		}
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return resolved, true
+	}
+	return "", false
+}
+
+// splitPathList parses a colon-separated list of directories, expanding a
+// leading "~/" to the user's home directory.
+func splitPathList(s string) []string {
+	home, _ := os.UserHomeDir()
+	var out []string
+	for _, p := range filepath.SplitList(s) {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if home != "" && (p == "~" || strings.HasPrefix(p, "~/")) {
+			p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+		out = append(out, filepath.Clean(p))
+	}
+	return out
+}
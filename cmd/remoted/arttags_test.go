@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// The fixtures below are built in memory, byte by byte, from the container
+// specs; the image payloads are short marker strings rather than real JPEGs.
+
+var (
+	frontCover = []byte("\xff\xd8\xff\xe0front\xff\x00cover")
+	backCover  = []byte("\xff\xd8\xff\xe0back")
+)
+
+func be32(n int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(n))
+}
+
+func le32(n int) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(n))
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// ── ID3v2 ────────────────────────────────────────────────────────────────────
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n>>21) & 0x7f, byte(n>>14) & 0x7f, byte(n>>7) & 0x7f, byte(n) & 0x7f}
+}
+
+// id3Tag wraps frames in a v2.<version> header with the given flags.
+func id3Tag(version, flags byte, frames ...[]byte) []byte {
+	body := cat(frames...)
+	return cat([]byte{'I', 'D', '3', version, 0, flags}, syncsafeBytes(len(body)), body)
+}
+
+// apicBody is a v2.3/v2.4 APIC body with a latin-1 description.
+func apicBody(picType byte, data []byte) []byte {
+	return cat([]byte{0}, []byte("image/jpeg\x00"), []byte{picType}, []byte("cover\x00"), data)
+}
+
+func id3v23Frame(id string, flags byte, body []byte) []byte {
+	return cat([]byte(id), be32(len(body)), []byte{0, flags}, body)
+}
+
+func id3v24Frame(id string, flags byte, body []byte) []byte {
+	return cat([]byte(id), syncsafeBytes(len(body)), []byte{0, flags}, body)
+}
+
+// addUnsync applies ID3 unsynchronisation: a zero byte after every 0xFF.
+func addUnsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff}, []byte{0xff, 0x00})
+}
+
+func TestReadID3Picture(t *testing.T) {
+	text := id3v23Frame("TIT2", 0, []byte("\x00Song"))
+	utf16Desc := cat([]byte{1}, []byte("image/png\x00"), []byte{3}, []byte{0xff, 0xfe, 'c', 0, 0, 0}, frontCover)
+	v24Unsync := cat(be32(len(apicBody(3, frontCover))), addUnsync(apicBody(3, frontCover)))
+
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{
+			name: "v2.3 front cover",
+			in:   id3Tag(3, 0, text, id3v23Frame("APIC", 0, apicBody(3, frontCover))),
+			want: frontCover,
+		},
+		{
+			name: "v2.3 front cover preferred over earlier picture",
+			in:   id3Tag(3, 0, id3v23Frame("APIC", 0, apicBody(4, backCover)), id3v23Frame("APIC", 0, apicBody(3, frontCover))),
+			want: frontCover,
+		},
+		{
+			name: "v2.3 first picture when there is no front cover",
+			in:   id3Tag(3, 0, id3v23Frame("APIC", 0, apicBody(4, backCover)), id3v23Frame("APIC", 0, apicBody(0, frontCover))),
+			want: backCover,
+		},
+		{
+			name: "v2.3 UTF-16 description",
+			in:   id3Tag(3, 0, id3v23Frame("APIC", 0, utf16Desc)),
+			want: frontCover,
+		},
+		{
+			name: "v2.3 tag-level unsynchronisation",
+			in: func() []byte {
+				tag := id3Tag(3, 0x80, text, id3v23Frame("APIC", 0, apicBody(3, frontCover)))
+				body := addUnsync(tag[10:])
+				return cat(tag[:6], syncsafeBytes(len(body)), body)
+			}(),
+			want: frontCover,
+		},
+		{
+			name: "v2.3 extended header",
+			in: func() []byte {
+				ext := cat(be32(6), make([]byte, 6))
+				body := cat(ext, id3v23Frame("APIC", 0, apicBody(3, frontCover)))
+				return cat([]byte{'I', 'D', '3', 3, 0, 0x40}, syncsafeBytes(len(body)), body)
+			}(),
+			want: frontCover,
+		},
+		{
+			name: "v2.4 syncsafe frame size",
+			in:   id3Tag(4, 0, id3v24Frame("APIC", 0, apicBody(3, bytes.Repeat([]byte{'x'}, 200)))),
+			want: bytes.Repeat([]byte{'x'}, 200),
+		},
+		{
+			name: "v2.4 frame-level unsynchronisation with data length indicator",
+			in:   id3Tag(4, 0, id3v24Frame("APIC", 0x03, v24Unsync)),
+			want: frontCover,
+		},
+		{
+			name: "v2.2 PIC",
+			in: id3Tag(2, 0,
+				cat([]byte("TT2"), []byte{0, 0, 5}, []byte("\x00Song")),
+				cat([]byte("PIC"), []byte{0, 0, byte(6 + len(frontCover))}, []byte{0}, []byte("JPG"), []byte{3}, []byte{0}, frontCover),
+			),
+			want: frontCover,
+		},
+		{
+			name: "padding after the last frame",
+			in:   id3Tag(3, 0, id3v23Frame("APIC", 0, apicBody(3, frontCover)), make([]byte, 64)),
+			want: frontCover,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadID3PictureMalformed(t *testing.T) {
+	good := id3Tag(3, 0, id3v23Frame("APIC", 0, apicBody(3, frontCover)))
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"header only", []byte("ID3\x03\x00")},
+		{"truncated tag", good[:len(good)-8]},
+		{"oversized tag size", cat([]byte{'I', 'D', '3', 3, 0, 0}, []byte{0x7f, 0x7f, 0x7f, 0x7f}, good[10:])},
+		{"unsupported version", cat([]byte{'I', 'D', '3', 5}, good[4:])},
+		{"frame longer than tag", id3Tag(3, 0, cat([]byte("APIC"), be32(1<<30), []byte{0, 0}, apicBody(3, frontCover)))},
+		{"v2.4 frame longer than tag", id3Tag(4, 0, cat([]byte("APIC"), []byte{0x7f, 0x7f, 0x7f, 0x7f}, []byte{0, 0}, apicBody(3, frontCover)))},
+		{"v2.2 frame longer than tag", id3Tag(2, 0, cat([]byte("PIC"), []byte{0xff, 0xff, 0xff}, frontCover))},
+		{"extended header longer than tag", cat([]byte{'I', 'D', '3', 3, 0, 0x40}, syncsafeBytes(8), be32(1<<31), make([]byte, 4))},
+		{"APIC without MIME terminator", id3Tag(3, 0, id3v23Frame("APIC", 0, []byte("\x00image/jpeg")))},
+		{"APIC without description terminator", id3Tag(3, 0, id3v23Frame("APIC", 0, []byte("\x00image/jpeg\x00\x03cover")))},
+		{"UTF-16 APIC with odd description", id3Tag(3, 0, id3v23Frame("APIC", 0, []byte("\x01image/jpeg\x00\x03\xff")))},
+		{"v2.2 PIC too short", id3Tag(2, 0, cat([]byte("PIC"), []byte{0, 0, 2}, []byte{0, 'J'}))},
+		{"v2.4 compressed frame", id3Tag(4, 0, id3v24Frame("APIC", 0x08, apicBody(3, frontCover)))},
+		{"no picture", id3Tag(3, 0, id3v23Frame("TIT2", 0, []byte("\x00Song")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err == nil {
+				t.Fatalf("got %q, want an error", got)
+			}
+		})
+	}
+}
+
+// ── FLAC ─────────────────────────────────────────────────────────────────────
+
+// flacPicture is a METADATA_BLOCK_PICTURE body.
+func flacPicture(picType int, data []byte) []byte {
+	return cat(be32(picType), be32(10), []byte("image/jpeg"), be32(0), make([]byte, 16), be32(len(data)), data)
+}
+
+func flacBlock(blockType byte, last bool, body []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	n := len(body)
+	return cat([]byte{blockType, byte(n >> 16), byte(n >> 8), byte(n)}, body)
+}
+
+func flacFile(blocks ...[]byte) []byte {
+	return cat([]byte("fLaC"), cat(blocks...))
+}
+
+func TestReadFLACPicture(t *testing.T) {
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{
+			name: "front cover",
+			in:   flacFile(streamInfo, flacBlock(6, true, flacPicture(3, frontCover))),
+			want: frontCover,
+		},
+		{
+			name: "front cover preferred over earlier picture",
+			in:   flacFile(streamInfo, flacBlock(6, false, flacPicture(4, backCover)), flacBlock(6, true, flacPicture(3, frontCover))),
+			want: frontCover,
+		},
+		{
+			name: "first picture when there is no front cover",
+			in:   flacFile(streamInfo, flacBlock(6, false, flacPicture(4, backCover)), flacBlock(4, true, make([]byte, 8))),
+			want: backCover,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFLACPictureMalformed(t *testing.T) {
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+	picture := flacBlock(6, true, flacPicture(3, frontCover))
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"magic only", []byte("fLaC")},
+		{"truncated block header", flacFile(streamInfo, []byte{0x86, 0})},
+		{"truncated picture block", flacFile(streamInfo, picture[:len(picture)-4])},
+		{"oversized block length", flacFile(streamInfo, []byte{0x86, 0xff, 0xff, 0xff}, flacPicture(3, frontCover))},
+		{"image length past end of block", flacFile(streamInfo, flacBlock(6, true, cat(be32(3), be32(0), be32(0), make([]byte, 16), be32(1<<30), frontCover)))},
+		{"MIME length past end of block", flacFile(streamInfo, flacBlock(6, true, cat(be32(3), be32(0xffffffff), frontCover)))},
+		{"no last block", flacFile(streamInfo)},
+		{"no picture", flacFile(flacBlock(0, true, make([]byte, 34)))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err == nil {
+				t.Fatalf("got %q, want an error", got)
+			}
+		})
+	}
+}
+
+// ── Ogg ──────────────────────────────────────────────────────────────────────
+
+// oggStream lays packets out as one logical stream, at most segsPerPage
+// lacing values per page, so long packets continue across pages.
+func oggStream(serial int, segsPerPage int, packets ...[]byte) [][]byte {
+	var lacing []byte
+	var data []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		data = append(data, p...)
+	}
+	var pages [][]byte
+	for seq := 0; len(lacing) > 0; seq++ {
+		k := segsPerPage
+		if k > len(lacing) {
+			k = len(lacing)
+		}
+		segs := lacing[:k]
+		size := 0
+		for _, s := range segs {
+			size += int(s)
+		}
+		hdr := make([]byte, 27)
+		copy(hdr, "OggS")
+		binary.LittleEndian.PutUint32(hdr[14:18], uint32(serial))
+		binary.LittleEndian.PutUint32(hdr[18:22], uint32(seq))
+		hdr[26] = byte(k)
+		pages = append(pages, cat(hdr, segs, data[:size]))
+		lacing, data = lacing[k:], data[size:]
+	}
+	return pages
+}
+
+// vorbisComments is a comment header body: vendor string plus comments.
+func vorbisComments(comments ...string) []byte {
+	out := cat(le32(6), []byte("vendor"), le32(len(comments)))
+	for _, c := range comments {
+		out = cat(out, le32(len(c)), []byte(c))
+	}
+	return out
+}
+
+func pictureComment(picType int, data []byte) string {
+	return "METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(flacPicture(picType, data))
+}
+
+func TestReadOggPicture(t *testing.T) {
+	vorbisID := cat([]byte("\x01vorbis"), make([]byte, 23))
+	bigCover := bytes.Repeat(frontCover, 100) // comment packet spans several pages
+	vorbis := func(comments ...string) []byte {
+		return cat([]byte("\x03vorbis"), vorbisComments(comments...))
+	}
+
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{
+			name: "vorbis",
+			in:   cat(oggStream(1, 255, vorbisID, vorbis("TITLE=Song", pictureComment(3, frontCover)))...),
+			want: frontCover,
+		},
+		{
+			name: "vorbis comment packet across pages",
+			in:   cat(oggStream(1, 2, vorbisID, vorbis("TITLE=Song", pictureComment(3, bigCover)))...),
+			want: bigCover,
+		},
+		{
+			name: "pages of another stream interleaved",
+			in: func() []byte {
+				ours := oggStream(1, 2, vorbisID, vorbis(pictureComment(3, bigCover)))
+				theirs := oggStream(2, 1, []byte("\x03vorbis other stream"))
+				return cat(ours[0], theirs[0], cat(ours[1:]...))
+			}(),
+			want: bigCover,
+		},
+		{
+			name: "front cover preferred over earlier picture",
+			in:   cat(oggStream(1, 255, vorbisID, vorbis(pictureComment(4, backCover), pictureComment(3, frontCover)))...),
+			want: frontCover,
+		},
+		{
+			name: "opus",
+			in:   cat(oggStream(7, 255, cat([]byte("OpusHead"), make([]byte, 11)), cat([]byte("OpusTags"), vorbisComments(pictureComment(3, frontCover))))...),
+			want: frontCover,
+		},
+		{
+			name: "ogg flac",
+			in: cat(oggStream(3, 255,
+				cat([]byte("\x7fFLAC"), make([]byte, 4), []byte("fLaC"), flacBlock(0, false, make([]byte, 34))),
+				flacBlock(6, true, flacPicture(3, frontCover)),
+			)...),
+			want: frontCover,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("got %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestReadOggPictureMalformed(t *testing.T) {
+	vorbisID := cat([]byte("\x01vorbis"), make([]byte, 23))
+	pages := oggStream(1, 2, vorbisID, cat([]byte("\x03vorbis"), vorbisComments(pictureComment(3, bytes.Repeat(frontCover, 100)))))
+	full := cat(pages...)
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"truncated page header", full[:20]},
+		{"truncated mid-packet", full[:len(full)-100]},
+		{"bad capture pattern", cat(pages[0], []byte("OggX"), pages[1][4:])},
+		{"no comment header", cat(oggStream(1, 255, vorbisID)...)},
+		{"comment header without picture", cat(oggStream(1, 255, vorbisID, cat([]byte("\x03vorbis"), vorbisComments("TITLE=Song")))...)},
+		{"oversized vendor length", cat(oggStream(1, 255, vorbisID, cat([]byte("\x03vorbis"), le32(1<<30), []byte("vendor")))...)},
+		{"oversized comment length", cat(oggStream(1, 255, vorbisID, cat([]byte("\x03vorbis"), le32(0), le32(1), le32(1<<30), []byte(pictureComment(3, frontCover))))...)},
+		{"bad base64 picture", cat(oggStream(1, 255, vorbisID, cat([]byte("\x03vorbis"), vorbisComments("METADATA_BLOCK_PICTURE=!!!")))...)},
+		{"picture with oversized image length", cat(oggStream(1, 255, vorbisID, cat([]byte("\x03vorbis"), vorbisComments(
+			"METADATA_BLOCK_PICTURE="+base64.StdEncoding.EncodeToString(cat(be32(3), be32(0), be32(0), make([]byte, 16), be32(1<<30), frontCover)),
+		)))...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err == nil {
+				t.Fatalf("got %d bytes, want an error", len(got))
+			}
+		})
+	}
+}
+
+// ── MP4 ──────────────────────────────────────────────────────────────────────
+
+func mp4Box(kind string, payload ...[]byte) []byte {
+	body := cat(payload...)
+	return cat(be32(8+len(body)), []byte(kind), body)
+}
+
+// mp4File builds ftyp + moov/udta/meta/ilst/covr/data around image. An ISO
+// meta box carries 4 bytes of version/flags before its children; QuickTime's
+// doesn't.
+func mp4File(isoMeta bool, image []byte) []byte {
+	hdlr := mp4Box("hdlr", make([]byte, 4), []byte("mdirappl"), make([]byte, 9))
+	ilst := mp4Box("ilst",
+		mp4Box("\xa9nam", mp4Box("data", be32(1), be32(0), []byte("Song"))),
+		mp4Box("covr", mp4Box("data", be32(13), be32(0), image)),
+	)
+	var meta []byte
+	if isoMeta {
+		meta = mp4Box("meta", make([]byte, 4), hdlr, ilst)
+	} else {
+		meta = mp4Box("meta", hdlr, ilst)
+	}
+	return cat(
+		mp4Box("ftyp", []byte("M4A "), be32(0)),
+		mp4Box("mdat", make([]byte, 32)),
+		mp4Box("moov", mp4Box("mvhd", make([]byte, 100)), mp4Box("udta", meta)),
+	)
+}
+
+func TestReadMP4Picture(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"iso meta", mp4File(true, frontCover)},
+		{"quicktime meta", mp4File(false, frontCover)},
+		{
+			name: "64-bit box size",
+			in: func() []byte {
+				f := mp4File(true, frontCover)
+				i := bytes.Index(f, []byte("mdat"))
+				large := cat(be32(1), []byte("mdat"), binary.BigEndian.AppendUint64(nil, 16+32), make([]byte, 32))
+				return cat(f[:i-4], large, f[i+4+32:])
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !bytes.Equal(got, frontCover) {
+				t.Fatalf("got %q, want %q", got, frontCover)
+			}
+		})
+	}
+}
+
+func TestReadMP4PictureMalformed(t *testing.T) {
+	full := mp4File(true, frontCover)
+	moov := bytes.Index(full, []byte("moov")) - 4
+	withSize := func(at int, size []byte) []byte {
+		out := bytes.Clone(full)
+		copy(out[at:], size)
+		return out
+	}
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"truncated", full[:len(full)-4]},
+		{"box past end of file", withSize(moov, be32(1<<30))},
+		{"box smaller than its header", withSize(moov, be32(4))},
+		{"64-bit size past end of file", cat(full[:moov], be32(1), []byte("moov"), binary.BigEndian.AppendUint64(nil, 1<<62))},
+		{"64-bit size overflowing int64", cat(full[:moov], be32(1), []byte("moov"), binary.BigEndian.AppendUint64(nil, 1<<63-1))},
+		{"negative 64-bit size", cat(full[:moov], be32(1), []byte("moov"), binary.BigEndian.AppendUint64(nil, 1<<63))},
+		{"empty data box", mp4File(true, nil)},
+		{"no covr", []byte(strings.Replace(string(full), "covr", "cprt", 1))},
+		{"no moov", full[:moov]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := embeddedArt(bytes.NewReader(tt.in), int64(len(tt.in)))
+			if err == nil {
+				t.Fatalf("got %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestEmbeddedArtUnknownContainer(t *testing.T) {
+	for _, in := range [][]byte{nil, []byte("RIFF\x00\x00\x00\x00WAVE"), []byte("ID")} {
+		if _, err := embeddedArt(bytes.NewReader(in), int64(len(in))); !errors.Is(err, errNoEmbeddedArt) {
+			t.Errorf("%q: err = %v, want errNoEmbeddedArt", in, err)
+		}
+	}
+}
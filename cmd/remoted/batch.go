@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// POST /batch runs an ordered list of commands in one request. Each step is
+// dispatched to the same handler as the standalone endpoint, but player
+// selection for every step sees one player snapshot taken up front, so a
+// step can't retarget because an earlier one changed which player is playing.
+
+const (
+	batchMaxSteps = 32
+	batchTimeout  = 20 * time.Second
+)
+
+// batchRoutes lists the command endpoints a batch may call.
+var batchRoutes = map[string]http.HandlerFunc{
+	"/player/playpause":          playPauseHandler,
+	"/player/play":               playHandler,
+	"/player/pause":              pauseHandler,
+	"/player/stop":               stopHandler,
+	"/player/next":               nextHandler,
+	"/player/prev":               previousHandler,
+	"/player/seek":               seekHandler,
+	"/player/tracklist/goto":     trackListGoToHandler,
+	"/player/playlists/activate": activatePlaylistHandler,
+	"/player/open":               openHandler,
+	"/player/raise":              raiseHandler,
+	"/player/quit":               quitHandler,
+	"/player/fullscreen":         fullscreenHandler,
+	"/player/url":                setPlayerURLHandler,
+	"/players/pause":             pauseAllHandler,
+	"/players/stop":              stopAllHandler,
+	"/players/mute":              muteAllHandler,
+	"/volume":                    volumeHandler,
+}
+
+type batchStep struct {
+	Path     string          `json:"path"`
+	Player   string          `json:"player,omitempty"`
+	Body     json.RawMessage `json:"body,omitempty"`
+	IfMatch  string          `json:"if_match,omitempty"`
+	IfTrack  string          `json:"if_track_id,omitempty"`
+	IfStatus string          `json:"if_status,omitempty"`
+}
+
+type batchRequest struct {
+	Steps       []batchStep `json:"steps"`
+	StopOnError bool        `json:"stop_on_error,omitempty"`
+}
+
+type batchStepResult struct {
+	Index   int         `json:"index"`
+	Path    string      `json:"path"`
+	Status  int         `json:"status,omitempty"`
+	OK      bool        `json:"ok"`
+	Skipped bool        `json:"skipped,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	OK      bool              `json:"ok"`
+	Stopped bool              `json:"stopped,omitempty"`
+	Steps   []batchStepResult `json:"steps"`
+}
+
+type playerSnapshotKey struct{}
+
+// withPlayerSnapshot makes listPlayers (and so pickPlayer) return players
+// instead of querying the bus for requests derived from ctx.
+func withPlayerSnapshot(ctx context.Context, players []playerInfo) context.Context {
+	return context.WithValue(ctx, playerSnapshotKey{}, players)
+}
+
+func playerSnapshot(ctx context.Context) ([]playerInfo, bool) {
+	players, ok := ctx.Value(playerSnapshotKey{}).([]playerInfo)
+	return players, ok
+}
+
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Steps) == 0 {
+		http.Error(w, "steps required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Steps) > batchMaxSteps {
+		http.Error(w, fmt.Sprintf("at most %d steps per batch", batchMaxSteps), http.StatusBadRequest)
+		return
+	}
+	// Validate every path before running anything so a typo in step 3
+	// doesn't leave steps 1 and 2 applied.
+	targets := make([]*url.URL, len(req.Steps))
+	for i, step := range req.Steps {
+		u, err := url.Parse(step.Path)
+		if err != nil || batchRoutes[u.Path] == nil {
+			http.Error(w, fmt.Sprintf("step %d: unsupported path %q", i, step.Path), http.StatusBadRequest)
+			return
+		}
+		targets[i] = u
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), batchTimeout)
+	defer cancel()
+
+	players, err := listPlayers(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list players: %v", err), http.StatusInternalServerError)
+		return
+	}
+	ctx = withPlayerSnapshot(ctx, players)
+
+	resp := batchResponse{OK: true, Steps: make([]batchStepResult, 0, len(req.Steps))}
+	for i, step := range req.Steps {
+		if resp.Stopped {
+			resp.Steps = append(resp.Steps, batchStepResult{Index: i, Path: step.Path, Skipped: true})
+			continue
+		}
+		res := runBatchStep(ctx, step, targets[i])
+		res.Index = i
+		resp.Steps = append(resp.Steps, res)
+		if !res.OK {
+			resp.OK = false
+			resp.Stopped = req.StopOnError
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// runBatchStep builds the request the standalone endpoint would receive and
+// records the handler's response.
+func runBatchStep(ctx context.Context, step batchStep, target *url.URL) batchStepResult {
+	res := batchStepResult{Path: step.Path}
+	q := target.Query()
+	if step.Player != "" {
+		q.Set("player", step.Player)
+	}
+	if step.IfTrack != "" {
+		q.Set("if_track_id", step.IfTrack)
+	}
+	if step.IfStatus != "" {
+		q.Set("if_status", step.IfStatus)
+	}
+	u := *target
+	u.RawQuery = q.Encode()
+
+	body := []byte(step.Body)
+	if len(body) == 0 || string(body) == "null" {
+		body = nil
+	}
+	sub, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if body != nil {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	if step.IfMatch != "" {
+		sub.Header.Set("If-Match", step.IfMatch)
+	}
+
+	rec := &batchRecorder{header: http.Header{}, code: http.StatusOK}
+	batchRoutes[target.Path](rec, sub)
+
+	res.Status = rec.code
+	res.OK = rec.code < 300
+	out := bytes.TrimSpace(rec.body.Bytes())
+	if !res.OK {
+		res.Error = string(out)
+		return res
+	}
+	var decoded interface{}
+	if json.Unmarshal(out, &decoded) == nil {
+		res.Result = decoded
+	} else if len(out) > 0 {
+		res.Result = string(out)
+	}
+	return res
+}
+
+// batchRecorder captures a handler's response for one batch step.
+type batchRecorder struct {
+	header http.Header
+	code   int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header { return r.header }
+
+func (r *batchRecorder) WriteHeader(code int) {
+	if !r.wrote {
+		r.code = code
+		r.wrote = true
+	}
+}
+
+func (r *batchRecorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fileConfig is the optional JSON config file (-config / REMOTED_CONFIG).
+// Flags and env vars cover simple scalars; structured settings live here.
+// Every section is optional; missing sections keep the built-in defaults.
+type fileConfig struct {
+	ArtProviders *artChainConfig  `json:"art_providers,omitempty"`
+	Outbound     *outboundConfig  `json:"outbound,omitempty"`
+	TMDb         *tmdbImageConfig `json:"tmdb,omitempty"`
+	Services     []serviceRule    `json:"services,omitempty"`
+	Selection    *selectionConfig `json:"selection,omitempty"`
+	Players      []playerRule     `json:"players,omitempty"`
+}
+
+func defaultConfigPath() string {
+	if dir, err := os.UserConfigDir(); err == nil && dir != "" {
+		return filepath.Join(dir, "umr", "remoted.json")
+	}
+	return ""
+}
+
+// loadFileConfig reads the config file at path. A missing file is not an
+// error: remoted runs fine on flags and env vars alone.
+func loadFileConfig(path string) (fileConfig, error) {
+	var fc fileConfig
+	if path == "" {
+		return fc, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fc, nil
+	}
+	if err != nil {
+		return fc, err
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return fc, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return fc, nil
+}
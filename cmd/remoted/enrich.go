@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Third-party metadata lookups (TMDb, MusicBrainz, iTunes) never run on the
+// request path. Providers check their cache and, on a miss, hand the lookup
+// to this background worker pool; once it lands in the cache a WebSocket
+// broadcast re-resolves art for every client. Jobs are deduplicated by key, so
+// many clients hitting the same cache miss cause one upstream request.
+
+const (
+	enrichWorkers    = 2
+	enrichQueueSize  = 64
+	enrichJobTimeout = 15 * time.Second
+)
+
+type enrichJob struct {
+	key string
+	fn  func(ctx context.Context)
+}
+
+type enricher struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	jobs    chan enrichJob
+}
+
+var enrichment = newEnricher()
+
+func newEnricher() *enricher {
+	return &enricher{
+		pending: make(map[string]bool),
+		jobs:    make(chan enrichJob, enrichQueueSize),
+	}
+}
+
+// run starts the worker pool; it returns when ctx is cancelled.
+func (e *enricher) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < enrichWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-e.jobs:
+					e.do(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (e *enricher) do(parent context.Context, job enrichJob) {
+	ctx, cancel := context.WithTimeout(parent, enrichJobTimeout)
+	defer cancel()
+	job.fn(ctx)
+
+	e.mu.Lock()
+	delete(e.pending, job.key)
+	e.mu.Unlock()
+
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+}
+
+// Go schedules fn under key unless a job with the same key is already queued
+// or running. It never blocks; if the queue is full the job is dropped and
+// will be retried on the next broadcast.
+func (e *enricher) Go(key string, fn func(ctx context.Context)) {
+	e.mu.Lock()
+	if e.pending[key] {
+		e.mu.Unlock()
+		return
+	}
+	e.pending[key] = true
+	e.mu.Unlock()
+
+	select {
+	case e.jobs <- enrichJob{key: key, fn: fn}:
+	default:
+		e.mu.Lock()
+		delete(e.pending, key)
+		e.mu.Unlock()
+		log.Printf("warn: enrichment queue full, dropping %s", key)
+	}
+}
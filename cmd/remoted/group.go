@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+	"github.com/godbus/dbus/v5"
+)
+
+// Group controls act on every player from listPlayers (MPRIS and MPD) instead
+// of the one pickPlayer would choose: "pause everything", "stop all browsers",
+// "mute everything except Spotify". Each player is handled independently and
+// gets its own entry in the response.
+
+// browserNames matches browser bus names and identities; their MPRIS players
+// are tabs, which is what "stop all browsers" is after.
+var browserNames = []string{"chromium", "chrome", "firefox", "brave", "vivaldi", "edge", "opera", "epiphany", "falkon", "librewolf", "floorp"}
+
+// groupRequest selects players. Every field is optional; the same selectors
+// may be given as comma-separated query parameters.
+type groupRequest struct {
+	Only     []string `json:"only,omitempty"`
+	Except   []string `json:"except,omitempty"`
+	Browsers bool     `json:"browsers,omitempty"`
+	Mute     *bool    `json:"mute,omitempty"`
+}
+
+type groupResult struct {
+	Player         string   `json:"player"`
+	BusName        string   `json:"bus_name"`
+	OK             bool     `json:"ok"`
+	Skipped        bool     `json:"skipped,omitempty"`
+	Reason         string   `json:"reason,omitempty"`
+	PlaybackStatus string   `json:"playback_status,omitempty"`
+	Volume         *float64 `json:"volume,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+type groupResponse struct {
+	Action  string        `json:"action"`
+	OK      bool          `json:"ok"`
+	Count   int           `json:"count"`
+	Results []groupResult `json:"results"`
+}
+
+var (
+	// mutedVolumes remembers each player's volume before a group mute so an
+	// unmute can restore it.
+	mutedVolumesMu sync.Mutex
+	mutedVolumes   = map[string]float64{}
+)
+
+func pauseAllHandler(w http.ResponseWriter, r *http.Request) {
+	groupHandler(w, r, "pause")
+}
+
+func stopAllHandler(w http.ResponseWriter, r *http.Request) {
+	groupHandler(w, r, "stop")
+}
+
+func muteAllHandler(w http.ResponseWriter, r *http.Request) {
+	groupHandler(w, r, "mute")
+}
+
+func groupHandler(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req groupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	req.Only = append(req.Only, splitList(q.Get("only"))...)
+	req.Except = append(req.Except, splitList(q.Get("except"))...)
+	if v := q.Get("browsers"); v == "1" || strings.EqualFold(v, "true") {
+		req.Browsers = true
+	}
+	mute := true
+	if req.Mute != nil {
+		mute = *req.Mute
+	} else if v := q.Get("mute"); v == "0" || strings.EqualFold(v, "false") {
+		mute = false
+	}
+	if action == "mute" && !mute {
+		action = "unmute"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	players, err := listPlayers(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list players: %v", err), http.StatusInternalServerError)
+		return
+	}
+	targets := req.filter(players)
+
+	// Players are independent, so a slow one doesn't hold up the rest.
+	results := make([]groupResult, len(targets))
+	var wg sync.WaitGroup
+	for i, p := range targets {
+		wg.Add(1)
+		go func(i int, p playerInfo) {
+			defer wg.Done()
+			results[i] = groupApply(ctx, p, action)
+		}(i, p)
+	}
+	wg.Wait()
+
+	resp := groupResponse{Action: action, OK: true, Count: len(targets), Results: results}
+	for _, res := range results {
+		if !res.OK {
+			resp.OK = false
+		}
+	}
+
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// filter keeps the players matched by the request's selectors, in order.
+func (req groupRequest) filter(players []playerInfo) []playerInfo {
+	out := make([]playerInfo, 0, len(players))
+	for _, p := range players {
+		if len(req.Only) > 0 && !matchesAnyPlayer(p, req.Only) {
+			continue
+		}
+		if matchesAnyPlayer(p, req.Except) {
+			continue
+		}
+		if req.Browsers && !isBrowserPlayer(p) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func matchesAnyPlayer(p playerInfo, names []string) bool {
+	for _, n := range names {
+		if playerMatches(p, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBrowserPlayer(p playerInfo) bool {
+	bus := strings.ToLower(strings.TrimPrefix(p.BusName, "org.mpris.MediaPlayer2."))
+	identity := strings.ToLower(p.Identity)
+	for _, name := range browserNames {
+		if strings.HasPrefix(bus, name) || strings.Contains(identity, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// groupApply runs one action on one player. Pause and stop skip players that
+// are already in the target state rather than waking them up.
+func groupApply(ctx context.Context, p playerInfo, action string) groupResult {
+	res := groupResult{Player: p.Identity, BusName: p.BusName, PlaybackStatus: p.PlaybackStatus}
+	switch action {
+	case "pause", "stop":
+		if !p.CanControl {
+			res.OK, res.Skipped, res.Reason = true, true, "not controllable"
+			return res
+		}
+		want := transportActions[action].Want
+		if strings.EqualFold(p.PlaybackStatus, want) || (action == "pause" && strings.EqualFold(p.PlaybackStatus, "Stopped")) {
+			res.OK, res.Skipped, res.Reason = true, true, "already "+strings.ToLower(p.PlaybackStatus)
+			return res
+		}
+		status, err := applyTransport(ctx, p, action)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.OK, res.PlaybackStatus = true, status
+	case "mute", "unmute":
+		vol, err := setPlayerMuted(p.BusName, action == "mute")
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.OK, res.Volume = true, &vol
+	default:
+		res.Error = fmt.Sprintf("unknown action %q", action)
+	}
+	return res
+}
+
+// setPlayerMuted sets the player's own volume (MPRIS Volume, MPD setvol) to
+// zero, remembering the previous level, or restores it. It returns the
+// resulting volume (0.0–1.0).
+func setPlayerMuted(busName string, mute bool) (float64, error) {
+	current, err := playerVolume(busName)
+	if err != nil {
+		return 0, err
+	}
+	mutedVolumesMu.Lock()
+	defer mutedVolumesMu.Unlock()
+	if mute {
+		if current == 0 {
+			return 0, nil
+		}
+		mutedVolumes[busName] = current
+		return 0, setPlayerVolume(busName, 0)
+	}
+	prev, ok := mutedVolumes[busName]
+	if !ok || current != 0 {
+		// Not muted by us (or changed since); leave it alone.
+		delete(mutedVolumes, busName)
+		return current, nil
+	}
+	delete(mutedVolumes, busName)
+	return prev, setPlayerVolume(busName, prev)
+}
+
+func playerVolume(busName string) (float64, error) {
+	if busName == "mpd" {
+		c, err := mpd.Dial("tcp", mpdAddr)
+		if err != nil {
+			return 0, fmt.Errorf("mpd dial: %w", err)
+		}
+		defer c.Close()
+		status, err := c.Status()
+		if err != nil {
+			return 0, fmt.Errorf("mpd status: %w", err)
+		}
+		vol, err := strconv.Atoi(status["volume"])
+		if err != nil || vol < 0 {
+			return 0, errors.New("mpd has no mixer")
+		}
+		return float64(vol) / 100, nil
+	}
+	conn, err := sessionBus()
+	if err != nil {
+		return 0, fmt.Errorf("session bus: %w", err)
+	}
+	v, err := conn.Object(busName, "/org/mpris/MediaPlayer2").GetProperty("org.mpris.MediaPlayer2.Player.Volume")
+	if err != nil {
+		return 0, fmt.Errorf("read volume: %w", err)
+	}
+	var vol float64
+	if err := v.Store(&vol); err != nil {
+		return 0, fmt.Errorf("read volume: %w", err)
+	}
+	return vol, nil
+}
+
+func setPlayerVolume(busName string, vol float64) error {
+	vol = clamp(vol, 0.0, 1.0)
+	if busName == "mpd" {
+		c, err := mpd.Dial("tcp", mpdAddr)
+		if err != nil {
+			return fmt.Errorf("mpd dial: %w", err)
+		}
+		defer c.Close()
+		return c.SetVolume(int(vol*100 + 0.5))
+	}
+	conn, err := sessionBus()
+	if err != nil {
+		return fmt.Errorf("session bus: %w", err)
+	}
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	return obj.SetProperty("org.mpris.MediaPlayer2.Player.Volume", dbus.MakeVariant(vol))
+}
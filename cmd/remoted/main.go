@@ -43,26 +43,78 @@ var (
 var (
 	lastPlayerMu sync.RWMutex
 	lastPlayer   string
+	lastPlayerAt time.Time
 )
 
 var (
 	artCacheDir string
 	tmdbKey     string
 	mpdAddr     string
+	musicRoots  []string
+	artRoots    []string
 )
 
 type tmdbCacheEntry struct {
-	URL       string
-	StoredAt  time.Time
-	Errored   bool
-	Provider  string
-	QueryName string
+	URL       string    `json:"url,omitempty"`
+	StoredAt  time.Time `json:"stored_at"`
+	Errored   bool      `json:"errored,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	QueryName string    `json:"query_name,omitempty"`
+
+	TMDb *tmdbDetails `json:"tmdb,omitempty"`
+
+	// Transient marks a miss caused by a failed request (timeout, 5xx,
+	// offline) rather than an answer of "not found". It is retried after
+	// lookupRetryAfter and never saved to disk.
+	Transient bool `json:"-"`
 }
 
-var tmdbCache = struct {
+// lookupRetryAfter is how long a transient miss is served from the cache
+// before the lookup is tried again.
+const lookupRetryAfter = 5 * time.Minute
+
+// errLookupNoMatch is returned by third-party lookups when the service
+// answered but had nothing usable; any other error is treated as transient.
+var errLookupNoMatch = errors.New("no match")
+
+// lookupCache holds third-party lookup results (including misses) for ttl.
+// Transient misses are kept only for lookupRetryAfter.
+type lookupCache struct {
 	mu    sync.RWMutex
+	ttl   time.Duration
 	store map[string]tmdbCacheEntry
-}{store: make(map[string]tmdbCacheEntry)}
+}
+
+func newLookupCache(ttl time.Duration) *lookupCache {
+	return &lookupCache{ttl: ttl, store: make(map[string]tmdbCacheEntry)}
+}
+
+// Get returns a fresh entry for key, if any.
+func (c *lookupCache) Get(key string) (tmdbCacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.store[key]
+	if !ok || time.Since(e.StoredAt) >= c.entryTTL(e) {
+		return tmdbCacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *lookupCache) entryTTL(e tmdbCacheEntry) time.Duration {
+	if e.Transient && lookupRetryAfter < c.ttl {
+		return lookupRetryAfter
+	}
+	return c.ttl
+}
+
+func (c *lookupCache) Set(key string, e tmdbCacheEntry) {
+	c.mu.Lock()
+	c.store[key] = e
+	c.mu.Unlock()
+	markStateDirty()
+}
+
+var tmdbCache = newLookupCache(12 * time.Hour)
 
 var globalHub *wsHub
 var playerURLs = newPlayerURLStore()
@@ -78,13 +130,20 @@ type Config struct {
 	ArtCache     string
 	TMDBKey      string
 	MPDAddr      string
+	MusicRoots   string
+	ArtRoots     string
+	ConfigPath   string
+	Offline      bool
 	PrintVersion bool
 }
 
+// playerURLTTL bounds how long a URL posted via /player/url is trusted.
+const playerURLTTL = 10 * time.Minute
+
 type playerURLRecord struct {
-	URL       string
-	TrackID   string
-	UpdatedAt time.Time
+	URL       string    `json:"url"`
+	TrackID   string    `json:"track_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type playerURLStore struct {
@@ -105,6 +164,7 @@ func (s *playerURLStore) key(bus, trackID string) string {
 
 func (s *playerURLStore) Set(bus, trackID, url string) {
 	s.mu.Lock()
+	defer markStateDirty()
 	defer s.mu.Unlock()
 	rec := playerURLRecord{
 		URL:       url,
@@ -119,7 +179,6 @@ func (s *playerURLStore) Set(bus, trackID, url string) {
 }
 
 func (s *playerURLStore) Get(bus, trackID string) string {
-	const ttl = 10 * time.Minute
 	keyExact := s.key(bus, trackID)
 	keyBus := s.key(bus, "")
 
@@ -132,7 +191,7 @@ func (s *playerURLStore) Get(bus, trackID string) string {
 	if !ok {
 		return ""
 	}
-	if time.Since(rec.UpdatedAt) > ttl {
+	if time.Since(rec.UpdatedAt) > playerURLTTL {
 		s.mu.Lock()
 		delete(s.urls, keyExact)
 		if keyExact != keyBus {
@@ -144,6 +203,28 @@ func (s *playerURLStore) Get(bus, trackID string) string {
 	return rec.URL
 }
 
+type configResponse struct {
+	Version     string   `json:"version"`
+	BindAddr    string   `json:"bind"`
+	Port        int      `json:"port"`
+	ArtCache    string   `json:"art_cache"`
+	StateFile   string   `json:"state_file"`
+	ArtRoots    []string `json:"art_roots"`
+	MusicRoots  []string `json:"music_roots"`
+	MPDAddr     string   `json:"mpd_addr,omitempty"`
+	TMDBEnabled bool     `json:"tmdb_enabled"`
+	Offline     bool     `json:"offline"`
+	Proxy       string   `json:"proxy,omitempty"`
+	UserAgent   string   `json:"user_agent"`
+
+	Endpoints outboundEndpoints `json:"endpoints"`
+
+	ArtProviders      artChainConfig   `json:"art_providers"`
+	KnownArtProviders []string         `json:"known_art_providers"`
+	Services          []string         `json:"services"`
+	Selection         *selectionConfig `json:"selection,omitempty"`
+}
+
 type healthResponse struct {
 	Status        string `json:"status"`
 	Version       string `json:"version"`
@@ -163,9 +244,36 @@ func main() {
 	artCacheDir = cfg.ArtCache
 	tmdbKey = strings.TrimSpace(cfg.TMDBKey)
 	mpdAddr = strings.TrimSpace(cfg.MPDAddr)
+	musicRoots = splitPathList(cfg.MusicRoots)
+	artRoots = splitPathList(cfg.ArtRoots)
+	fileCfg, err := loadFileConfig(cfg.ConfigPath)
+	if err != nil {
+		log.Fatalf("failed to load config file: %v", err)
+	}
+	artChains = mergeArtChains(defaultArtChains(), fileCfg.ArtProviders)
+	tmdbImages = mergeTMDbImageConfig(fileCfg.TMDb)
+	if serviceRules, err = mergeServiceRules(serviceRules, fileCfg.Services); err != nil {
+		log.Fatalf("invalid services config: %v", err)
+	}
+	if selection, err = newSelectionPolicy(fileCfg.Selection); err != nil {
+		log.Fatalf("invalid selection config: %v", err)
+	}
+	if playerRules, err = compilePlayerRules(fileCfg.Players); err != nil {
+		log.Fatalf("invalid players config: %v", err)
+	}
+	if err := configureOutbound(fileCfg.Outbound, cfg.Offline); err != nil {
+		log.Fatalf("invalid outbound config: %v", err)
+	}
+	if offlineMode() {
+		log.Printf("offline mode: third-party lookups disabled")
+	}
 	if err := os.MkdirAll(artCacheDir, 0o755); err != nil {
 		log.Fatalf("failed to create art cache dir: %v", err)
 	}
+	persistentState.path = defaultStatePath(artCacheDir)
+	if err := persistentState.load(); err != nil {
+		log.Printf("warn: load %s: %v", persistentState.path, err)
+	}
 
 	hub := newWSHub()
 	globalHub = hub
@@ -178,13 +286,29 @@ func main() {
 	fileServer := http.FileServer(http.FS(staticFS))
 
 	mux.HandleFunc("/healthz", healthHandler(cfg))
+	mux.Handle("/config", requireToken(cfg.Token, configHandler(cfg)))
 	mux.Handle("/players", requireToken(cfg.Token, http.HandlerFunc(playersHandler)))
+	mux.Handle("/players/pause", requireToken(cfg.Token, http.HandlerFunc(pauseAllHandler)))
+	mux.Handle("/players/stop", requireToken(cfg.Token, http.HandlerFunc(stopAllHandler)))
+	mux.Handle("/players/mute", requireToken(cfg.Token, http.HandlerFunc(muteAllHandler)))
 	mux.Handle("/player/status", requireToken(cfg.Token, http.HandlerFunc(playerStatusHandler)))
 	mux.Handle("/nowplaying", requireToken(cfg.Token, http.HandlerFunc(nowPlayingHandler)))
 	mux.Handle("/player/playpause", requireToken(cfg.Token, http.HandlerFunc(playPauseHandler)))
+	mux.Handle("/player/play", requireToken(cfg.Token, http.HandlerFunc(playHandler)))
+	mux.Handle("/player/pause", requireToken(cfg.Token, http.HandlerFunc(pauseHandler)))
+	mux.Handle("/player/stop", requireToken(cfg.Token, http.HandlerFunc(stopHandler)))
 	mux.Handle("/player/next", requireToken(cfg.Token, http.HandlerFunc(nextHandler)))
 	mux.Handle("/player/prev", requireToken(cfg.Token, http.HandlerFunc(previousHandler)))
 	mux.Handle("/player/seek", requireToken(cfg.Token, http.HandlerFunc(seekHandler)))
+	mux.Handle("/player/tracklist", requireToken(cfg.Token, http.HandlerFunc(trackListHandler)))
+	mux.Handle("/player/tracklist/goto", requireToken(cfg.Token, http.HandlerFunc(trackListGoToHandler)))
+	mux.Handle("/player/raise", requireToken(cfg.Token, http.HandlerFunc(raiseHandler)))
+	mux.Handle("/player/quit", requireToken(cfg.Token, http.HandlerFunc(quitHandler)))
+	mux.Handle("/player/fullscreen", requireToken(cfg.Token, http.HandlerFunc(fullscreenHandler)))
+	mux.Handle("/player/open", requireToken(cfg.Token, http.HandlerFunc(openHandler)))
+	mux.Handle("/player/playlists", requireToken(cfg.Token, http.HandlerFunc(playlistsHandler)))
+	mux.Handle("/player/playlists/activate", requireToken(cfg.Token, http.HandlerFunc(activatePlaylistHandler)))
+	mux.Handle("/batch", requireToken(cfg.Token, http.HandlerFunc(batchHandler)))
 	mux.Handle("/volume", requireToken(cfg.Token, http.HandlerFunc(volumeHandler)))
 	mux.Handle("/player/url", requireToken(cfg.Token, http.HandlerFunc(setPlayerURLHandler)))
 	mux.Handle("/art/", requireToken(cfg.Token, http.HandlerFunc(artHandler)))
@@ -204,6 +328,8 @@ func main() {
 	defer stop()
 
 	go hub.run(ctx)
+	go enrichment.run(ctx)
+	go persistentState.run(ctx)
 	go startSignalListener(ctx, hub)
 	if mpdAddr != "" {
 		go startMPDListener(ctx, hub)
@@ -229,6 +355,9 @@ func main() {
 	} else {
 		log.Printf("server stopped")
 	}
+	if err := persistentState.save(); err != nil {
+		log.Printf("warn: save %s: %v", persistentState.path, err)
+	}
 }
 
 func parseConfig() Config {
@@ -247,6 +376,10 @@ func parseConfig() Config {
 	flag.StringVar(&cfg.ArtCache, "art-cache", defaultArt, "artwork cache directory (default from REMOTED_ART_CACHE)")
 	flag.StringVar(&cfg.TMDBKey, "tmdb-key", defaultTMDB, "TMDb API key (default from REMOTED_TMDB_KEY)")
 	flag.StringVar(&cfg.MPDAddr, "mpd", os.Getenv("REMOTED_MPD_ADDR"), "MPD address host:port (default from REMOTED_MPD_ADDR; empty = disabled)")
+	flag.StringVar(&cfg.ArtRoots, "art-roots", getenvDefault("REMOTED_ART_ROOTS", "/tmp:/var/tmp"), "colon-separated dirs from which file:// mpris:artUrl images may be proxied (default from REMOTED_ART_ROOTS)")
+	flag.StringVar(&cfg.MusicRoots, "music-roots", getenvDefault("REMOTED_MUSIC_ROOTS", "~/Music"), "colon-separated dirs whose local files may have embedded art extracted (default from REMOTED_MUSIC_ROOTS)")
+	flag.BoolVar(&cfg.Offline, "offline", getenvBool("REMOTED_OFFLINE"), "disable all third-party lookups (default from REMOTED_OFFLINE)")
+	flag.StringVar(&cfg.ConfigPath, "config", getenvDefault("REMOTED_CONFIG", defaultConfigPath()), "JSON config file for structured settings (default from REMOTED_CONFIG; missing file is ignored)")
 	flag.BoolVar(&cfg.PrintVersion, "v", false, "print version and exit")
 
 	flag.Usage = func() {
@@ -277,6 +410,33 @@ func healthHandler(cfg Config) http.HandlerFunc {
 	}
 }
 
+// configHandler dumps the effective (parsed) configuration. Secrets are never included.
+func configHandler(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := configResponse{
+			Version:     cfg.Version,
+			BindAddr:    cfg.BindAddr,
+			Port:        cfg.Port,
+			ArtCache:    artCacheDir,
+			StateFile:   persistentState.path,
+			ArtRoots:    append([]string{}, artRoots...),
+			MusicRoots:  append([]string{}, musicRoots...),
+			MPDAddr:     mpdAddr,
+			TMDBEnabled: tmdbKey != "",
+			Offline:     offlineMode(),
+			Proxy:       redactProxy(outbound.Proxy),
+			UserAgent:   outbound.UserAgent,
+			Endpoints:   outbound.Endpoints,
+
+			ArtProviders:      artChains,
+			KnownArtProviders: artProviderNames(),
+			Services:          serviceRuleNames(),
+			Selection:         selection.settings,
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -305,6 +465,11 @@ func getenvInt(key string, fallback int) int {
 	return parsed
 }
 
+func getenvBool(key string) bool {
+	parsed, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && parsed
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -313,20 +478,71 @@ func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 
 type playerInfo struct {
 	BusName        string `json:"bus_name"`
+	ID             string `json:"id"`
+	Alias          string `json:"alias,omitempty"`
 	Identity       string `json:"identity"`
+	DesktopEntry   string `json:"desktop_entry,omitempty"`
 	PlaybackStatus string `json:"playback_status"`
 	CanControl     bool   `json:"can_control"`
 	IsActive       bool   `json:"is_active"`
 	PositionMillis int64  `json:"position_millis,omitempty"`
-	LengthMillis   int64  `json:"length_millis,omitempty"`
-	TrackID        string `json:"track_id,omitempty"`
-	Title          string `json:"title,omitempty"`
-	Artist         string `json:"artist,omitempty"`
-	Album          string `json:"album,omitempty"`
-	URL            string `json:"url,omitempty"`
-	ArtURL         string `json:"art_url,omitempty"`
-	ArtURLProxy    string `json:"art_url_proxy,omitempty"`
-	ArtHint        string `json:"art_hint,omitempty"`
+	// PositionUpdatedAt is when PositionMillis was read (Unix ms); with Rate a
+	// client can interpolate: pos + (now - updated_at) * rate while playing.
+	PositionUpdatedAt int64       `json:"position_updated_at,omitempty"`
+	Rate              float64     `json:"rate"`
+	LengthMillis      int64       `json:"length_millis,omitempty"`
+	TrackID           string      `json:"track_id,omitempty"`
+	Title             string      `json:"title,omitempty"`
+	RawTitle          string      `json:"raw_title,omitempty"`
+	Artist            string      `json:"artist,omitempty"`
+	Album             string      `json:"album,omitempty"`
+	URL               string      `json:"url,omitempty"`
+	ArtURL            string      `json:"art_url,omitempty"`
+	ArtURLProxy       string      `json:"art_url_proxy,omitempty"`
+	ArtHint           string      `json:"art_hint,omitempty"`
+	Palette           *artPalette `json:"palette,omitempty"`
+	IsLive            bool        `json:"is_live"`
+	// ETag identifies player+track+status for If-Match preconditions.
+	ETag string `json:"etag"`
+
+	// Window management capabilities (root org.mpris.MediaPlayer2 interface).
+	CanRaise         bool `json:"can_raise"`
+	CanQuit          bool `json:"can_quit"`
+	CanSetFullscreen bool `json:"can_set_fullscreen"`
+	Fullscreen       bool `json:"fullscreen"`
+
+	// Extended xesam metadata (MPD: the matching tags). Artist and Album above
+	// stay as the single display strings.
+	Artists      []string `json:"artists,omitempty"`
+	AlbumArtists []string `json:"album_artists,omitempty"`
+	Composers    []string `json:"composers,omitempty"`
+	Genres       []string `json:"genres,omitempty"`
+	TrackNumber  int      `json:"track_number,omitempty"`
+	DiscNumber   int      `json:"disc_number,omitempty"`
+	UserRating   *float64 `json:"user_rating,omitempty"`
+	UseCount     int      `json:"use_count,omitempty"`
+	BitrateKbps  int      `json:"bitrate_kbps,omitempty"`
+
+	// Streaming service matched by the service rules (see services.go).
+	Service      string        `json:"service,omitempty"`
+	ServiceLabel string        `json:"service_label,omitempty"`
+	ServiceIcon  string        `json:"service_icon,omitempty"`
+	ServiceTheme *serviceTheme `json:"service_theme,omitempty"`
+
+	// Episode structure parsed from the title (see titles.go); Show and
+	// EpisodeTitle are replaced by TMDb's names once a match is known.
+	Show         string `json:"show,omitempty"`
+	Season       int    `json:"season,omitempty"`
+	Episode      int    `json:"episode,omitempty"`
+	EpisodeTitle string `json:"episode_title,omitempty"`
+
+	// TMDb match for video sessions whose art chain includes "tmdb".
+	TMDbID        int    `json:"tmdb_id,omitempty"`
+	TMDbMediaType string `json:"tmdb_media_type,omitempty"`
+	StillURL      string `json:"still_url,omitempty"`
+	BackdropURL   string `json:"backdrop_url,omitempty"`
+	Year          int    `json:"year,omitempty"`
+	Overview      string `json:"overview,omitempty"`
 }
 
 type wsClient struct {
@@ -339,12 +555,17 @@ type wsHub struct {
 	mu      sync.RWMutex
 	clients map[*wsClient]struct{}
 	notify  chan struct{}
+	events  chan interface{}
 }
 
+// wsEventQueue bounds the out-of-band events waiting to be written.
+const wsEventQueue = 32
+
 func newWSHub() *wsHub {
 	return &wsHub{
 		clients: make(map[*wsClient]struct{}),
 		notify:  make(chan struct{}, 1),
+		events:  make(chan interface{}, wsEventQueue),
 	}
 }
 
@@ -388,6 +609,8 @@ func (h *wsHub) run(ctx context.Context) {
 				}
 			}
 			h.broadcast(context.Background())
+		case payload := <-h.events:
+			h.broadcastEvent(payload)
 		}
 	}
 }
@@ -407,6 +630,34 @@ func (h *wsHub) broadcast(ctx context.Context) {
 	}
 }
 
+// queueEvent hands an out-of-band event to the hub's goroutine without
+// blocking, so D-Bus and MPD listeners never wait on a slow client. Events
+// are dropped when the queue is full.
+func (h *wsHub) queueEvent(payload interface{}) {
+	select {
+	case h.events <- payload:
+	default:
+		log.Printf("warn: ws event queue full, dropping event")
+	}
+}
+
+// broadcastEvent sends an out-of-band event (a JSON object with an "event"
+// key) to every client. Clients tell events from player updates by that key.
+func (h *wsHub) broadcastEvent(payload interface{}) {
+	h.mu.RLock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range clients {
+		if err := h.write(c, payload); err != nil {
+			log.Printf("ws event failed: %v", err)
+		}
+	}
+}
+
 func (h *wsHub) sendNowPlaying(ctx context.Context, client *wsClient) error {
 	pctx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
 	defer cancel()
@@ -433,6 +684,8 @@ func (h *wsHub) write(client *wsClient, payload interface{}) error {
 }
 
 // startSignalListener listens for MPRIS changes and triggers broadcasts to connected WebSocket clients.
+// Position never arrives via PropertiesChanged, so Seeked is watched too: a jump
+// is pushed immediately with a fresh position_updated_at.
 func startSignalListener(ctx context.Context, hub *wsHub) {
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
@@ -441,13 +694,22 @@ func startSignalListener(ctx context.Context, hub *wsHub) {
 	}
 
 	propsMatch := "type='signal',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',path_namespace='/org/mpris/MediaPlayer2'"
+	seekedMatch := "type='signal',interface='org.mpris.MediaPlayer2.Player',member='Seeked',path='/org/mpris/MediaPlayer2'"
+	trackListMatch := "type='signal',interface='" + mprisTrackListIface + "',path='/org/mpris/MediaPlayer2'"
 	nameMatch := "type='signal',interface='org.freedesktop.DBus',member='NameOwnerChanged'"
 	_ = conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.AddMatch", 0, propsMatch)
+	_ = conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.AddMatch", 0, seekedMatch)
+	_ = conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.AddMatch", 0, trackListMatch)
 	_ = conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.AddMatch", 0, nameMatch)
 
 	sigCh := make(chan *dbus.Signal, 32)
 	conn.Signal(sigCh)
 
+	// TrackList signals carry the sender's unique name; keep the mapping back
+	// to MPRIS bus names current from NameOwnerChanged instead of asking the
+	// bus on every signal.
+	owners := mprisOwners(ctx, conn)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -458,6 +720,12 @@ func startSignalListener(ctx context.Context, hub *wsHub) {
 			if !ok || sig == nil {
 				return
 			}
+			if sig.Name == "org.freedesktop.DBus.NameOwnerChanged" {
+				trackNameOwner(owners, sig)
+			}
+			if strings.HasPrefix(sig.Name, mprisTrackListIface+".") {
+				hub.queueEvent(trackListEventFor(owners, sig))
+			}
 			if strings.HasPrefix(string(sig.Path), "/org/mpris/MediaPlayer2") {
 				hub.requestBroadcast()
 				continue
@@ -493,6 +761,7 @@ func playerStatusHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
 		return
 	}
+	w.Header().Set("ETag", player.ETag)
 	writeJSON(w, http.StatusOK, player)
 }
 
@@ -541,6 +810,9 @@ func playPauseHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
 		return
 	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
 
 	if info.BusName == "mpd" {
 		if err := mpdPlayPause(info.PlaybackStatus); err != nil {
@@ -606,6 +878,9 @@ func controlHandler(w http.ResponseWriter, r *http.Request, method string) {
 		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
 		return
 	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
 
 	if info.BusName == "mpd" {
 		cmd := "next"
@@ -648,6 +923,8 @@ func controlHandler(w http.ResponseWriter, r *http.Request, method string) {
 type seekRequest struct {
 	DeltaMillis  *int64 `json:"delta_ms,omitempty"`
 	TargetMillis *int64 `json:"target_ms,omitempty"`
+	IfTrackID    string `json:"if_track_id,omitempty"`
+	IfStatus     string `json:"if_status,omitempty"`
 }
 
 func seekHandler(w http.ResponseWriter, r *http.Request) {
@@ -675,6 +952,13 @@ func seekHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
 		return
 	}
+	if !requirePreconditions(w, preconditionsFrom(r).with(req.IfTrackID, req.IfStatus), info) {
+		return
+	}
+	if info.IsLive {
+		http.Error(w, fmt.Sprintf("%s is playing a live stream; seeking is not supported", info.Identity), http.StatusConflict)
+		return
+	}
 
 	if info.BusName == "mpd" {
 		switch {
@@ -737,12 +1021,43 @@ func seekHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// busConn is remoted's own session bus connection. dbus.SessionBus() hands
+// out one connection shared with every other user in the process, so a caller
+// closing it breaks all the others mid-call; this one is never closed by
+// callers and is reopened if the bus drops it.
+var busConn struct {
+	sync.Mutex
+	conn *dbus.Conn
+}
+
+// sessionBus returns remoted's session bus connection, connecting on first
+// use. Callers must not close it.
+func sessionBus() (*dbus.Conn, error) {
+	busConn.Lock()
+	defer busConn.Unlock()
+	if busConn.conn != nil && busConn.conn.Connected() {
+		return busConn.conn, nil
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	busConn.conn = conn
+	return conn, nil
+}
+
+// getAllProps reads every property of iface in one round-trip.
+func getAllProps(ctx context.Context, obj dbus.BusObject, iface string) (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+	err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, iface).Store(&props)
+	return props, err
+}
+
 func callPlayerMethod(ctx context.Context, busName, method string) error {
-	conn, err := dbus.SessionBus()
+	conn, err := sessionBus()
 	if err != nil {
 		return fmt.Errorf("session bus: %w", err)
 	}
-	defer conn.Close()
 
 	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
 	call := obj.CallWithContext(ctx, method, 0)
@@ -753,11 +1068,10 @@ func callPlayerMethod(ctx context.Context, busName, method string) error {
 }
 
 func seekPlayer(ctx context.Context, busName string, deltaMillis int64) error {
-	conn, err := dbus.SessionBus()
+	conn, err := sessionBus()
 	if err != nil {
 		return fmt.Errorf("session bus: %w", err)
 	}
-	defer conn.Close()
 
 	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
 	offsetMicros := deltaMillis * 1000
@@ -772,11 +1086,10 @@ func setPlayerPosition(ctx context.Context, busName, trackID string, targetMilli
 	if trackID == "" {
 		return fmt.Errorf("track ID is required for absolute seek")
 	}
-	conn, err := dbus.SessionBus()
+	conn, err := sessionBus()
 	if err != nil {
 		return fmt.Errorf("session bus: %w", err)
 	}
-	defer conn.Close()
 
 	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
 	call := obj.CallWithContext(ctx, "org.mpris.MediaPlayer2.Player.SetPosition", 0, dbus.ObjectPath(trackID), targetMillis*1000)
@@ -787,11 +1100,13 @@ func setPlayerPosition(ctx context.Context, busName, trackID string, targetMilli
 }
 
 func listPlayers(ctx context.Context) ([]playerInfo, error) {
-	conn, err := dbus.SessionBus()
+	if players, ok := playerSnapshot(ctx); ok {
+		return players, nil
+	}
+	conn, err := sessionBus()
 	if err != nil {
 		return nil, fmt.Errorf("session bus: %w", err)
 	}
-	defer conn.Close()
 
 	names, err := listNames(ctx, conn)
 	if err != nil {
@@ -818,7 +1133,10 @@ func listPlayers(ctx context.Context) ([]playerInfo, error) {
 		}
 	}
 
-	players = markActive(players)
+	players = markActive(applyPlayerRules(players))
+	for i := range players {
+		players[i].ETag = playerETag(players[i])
+	}
 	return players, nil
 }
 
@@ -839,46 +1157,17 @@ func pickPlayer(ctx context.Context, preferred string) (playerInfo, error) {
 	}
 	if preferred != "" {
 		for _, p := range players {
-			if p.BusName == preferred || p.Identity == preferred {
+			if playerMatches(p, preferred) {
 				return recordIfPlaying(p), nil
 			}
 		}
 		return playerInfo{}, fmt.Errorf("player %q not found", preferred)
 	}
-	last := getLastPlayer()
-	playing := func(p playerInfo) bool {
-		return strings.EqualFold(p.PlaybackStatus, "Playing")
-	}
-
-	// 1) If last player is still present and playing, stick with it.
-	if last != "" {
-		for _, p := range players {
-			if (p.BusName == last || p.Identity == last) && playing(p) {
-				return recordIfPlaying(p), nil
-			}
-		}
+	i := activePlayerIndex(players)
+	if i < 0 {
+		return playerInfo{}, fmt.Errorf("no selectable players (all ignored by the selection policy)")
 	}
-	// 2) Otherwise, choose any playing player.
-	for _, p := range players {
-		if playing(p) {
-			return recordIfPlaying(p), nil
-		}
-	}
-	// 3) If none playing, keep last if still present.
-	if last != "" {
-		for _, p := range players {
-			if p.BusName == last || p.Identity == last {
-				return p, nil
-			}
-		}
-	}
-	// 4) Fallback to first paused, else first.
-	for _, p := range players {
-		if strings.EqualFold(p.PlaybackStatus, "Paused") {
-			return p, nil
-		}
-	}
-	return players[0], nil
+	return recordIfPlaying(players[i]), nil
 }
 
 func fetchPlayerInfo(ctx context.Context, conn *dbus.Conn, busName string) (playerInfo, error) {
@@ -904,6 +1193,8 @@ func fetchPlayerInfo(ctx context.Context, conn *dbus.Conn, busName string) (play
 		CanControl:     asBool(canControlVariant),
 	}
 
+	populateRootProps(ctx, &info, obj)
+
 	metaVariant, err := obj.GetProperty("org.mpris.MediaPlayer2.Player.Metadata")
 	if err == nil {
 		populateMetadata(&info, metaVariant)
@@ -912,6 +1203,13 @@ func fetchPlayerInfo(ctx context.Context, conn *dbus.Conn, busName string) (play
 	positionVariant, err := obj.GetProperty("org.mpris.MediaPlayer2.Player.Position")
 	if err == nil {
 		info.PositionMillis = asInt64(positionVariant) / 1000
+		info.PositionUpdatedAt = time.Now().UnixMilli()
+	}
+	info.Rate = 1
+	if rateVariant, err := obj.GetProperty("org.mpris.MediaPlayer2.Player.Rate"); err == nil {
+		if rate, ok := rateVariant.Value().(float64); ok && rate > 0 {
+			info.Rate = rate
+		}
 	}
 
 	if info.URL == "" {
@@ -920,74 +1218,34 @@ func fetchPlayerInfo(ctx context.Context, conn *dbus.Conn, busName string) (play
 		}
 	}
 
-	// If we have no art from the player, attempt TMDb lookup for HBO/Max titles (when URL or identity suggests it).
-	if info.ArtURL == "" && info.ArtURLProxy == "" && tmdbKey != "" && isHBO(info) {
-		if art := tmdbLookup(ctx, info.Title); art != "" {
-			info.ArtURL = art
-			info.ArtHint = "tmdb"
-		}
-	}
+	normalizePlayerTitle(&info)
+	info.IsLive = isLiveSession(info)
 
-	// For Crunchyroll, override any existing artwork with TMDb lookup if possible.
-	// Chromium often provides low-quality temp file artwork, so we prefer TMDb.
-	// Falls back to Crunchyroll icon in frontend if parsing fails or TMDb returns nothing.
-	if tmdbKey != "" && isCrunchyroll(info) {
-		if parsedTitle := parseCrunchyrollTitle(info.Title); parsedTitle != "" {
-			if art := tmdbLookup(ctx, parsedTitle); art != "" {
-				info.ArtURL = art
-				info.ArtURLProxy = ""
-				info.ArtHint = "tmdb"
-			} else {
-				// Parsing succeeded but TMDb lookup failed - clear artwork to show Crunchyroll icon
-				info.ArtURL = ""
-				info.ArtURLProxy = ""
-			}
-		} else {
-			// Parsing failed (e.g., "Season 1..." or arc title) - clear artwork to show Crunchyroll icon
-			info.ArtURL = ""
-			info.ArtURLProxy = ""
-		}
-	}
+	// Artwork comes from the provider chain configured for this player/service.
+	resolveArt(ctx, &info, artRequest{})
 
+	info.Palette = paletteForInfo(info)
 	return info, nil
 }
 
-func markActive(players []playerInfo) []playerInfo {
-	last := getLastPlayer()
-	playing := func(p playerInfo) bool {
-		return strings.EqualFold(p.PlaybackStatus, "Playing")
-	}
-	// align with pickPlayer ordering
-	if last != "" {
-		for i, p := range players {
-			if (p.BusName == last || p.Identity == last) && playing(p) {
-				players[i].IsActive = true
-				return players
-			}
-		}
-	}
-	for i, p := range players {
-		if playing(p) {
-			players[i].IsActive = true
-			return players
-		}
-	}
-	if last != "" {
-		for i, p := range players {
-			if p.BusName == last || p.Identity == last {
-				players[i].IsActive = true
-				return players
-			}
-		}
+// isLiveSession reports whether a player is showing a live stream: its service
+// rule says so, or it is playing an http(s) URL that has no length (YouTube
+// Live, browser radio). A missing length alone isn't enough; plenty of
+// players leave it out for local files, or before a track has loaded.
+func isLiveSession(info playerInfo) bool {
+	if rule, ok := serviceRuleByName(info.Service); ok && rule.Live {
+		return true
 	}
-	for i, p := range players {
-		if strings.EqualFold(p.PlaybackStatus, "Paused") {
-			players[i].IsActive = true
-			return players
-		}
+	if info.LengthMillis > 0 || !strings.EqualFold(info.PlaybackStatus, "Playing") {
+		return false
 	}
-	if len(players) > 0 {
-		players[0].IsActive = true
+	lower := strings.ToLower(info.URL)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func markActive(players []playerInfo) []playerInfo {
+	if i := activePlayerIndex(players); i >= 0 {
+		players[i].IsActive = true
 	}
 	return players
 }
@@ -1013,24 +1271,10 @@ func fetchMPDInfo(ctx context.Context) (playerInfo, error) {
 	}
 	info := mpdToPlayerInfo(status, song)
 
-	// Art: try embedded art via readpicture first.
-	if songURI := song["file"]; songURI != "" {
-		if data, err := c.ReadPicture(songURI); err == nil && len(data) > 0 {
-			if proxy, err := cacheMPDPicture(data, songURI); err == nil {
-				info.ArtURLProxy = proxy
-			} else {
-				log.Printf("warn: mpd cache picture: %v", err)
-			}
-		}
-	}
-	// Art: fall back to MusicBrainz if no embedded art found.
-	if info.ArtURLProxy == "" && info.Artist != "" && info.Album != "" {
-		if artURL := musicBrainzArtURL(ctx, info.Artist, info.Album); artURL != "" {
-			info.ArtURL = artURL
-			info.ArtHint = "musicbrainz"
-		}
-	}
+	// Art: provider chain (by default readpicture, then MusicBrainz).
+	resolveArt(ctx, &info, artRequest{MPDClient: c, MPDSongURI: song["file"]})
 
+	info.Palette = paletteForInfo(info)
 	return info, nil
 }
 
@@ -1040,6 +1284,7 @@ func mpdToPlayerInfo(status, song mpd.Attrs) playerInfo {
 		BusName:    "mpd",
 		Identity:   "MPD",
 		CanControl: true,
+		Rate:       1,
 	}
 
 	switch status["state"] {
@@ -1056,10 +1301,38 @@ func mpdToPlayerInfo(status, song mpd.Attrs) playerInfo {
 	info.Album = song["Album"]
 	info.URL = song["file"]
 	info.TrackID = status["songid"]
+	info.Artists = nonEmpty(song["Artist"])
+	info.AlbumArtists = nonEmpty(song["AlbumArtist"])
+	info.Composers = nonEmpty(song["Composer"])
+	info.Genres = nonEmpty(song["Genre"])
+	info.TrackNumber = mpdTagNumber(song["Track"])
+	info.DiscNumber = mpdTagNumber(song["Disc"])
+	info.Year = yearOf(firstNonEmpty(song["OriginalDate"], song["Date"]))
+	if br, err := strconv.Atoi(status["bitrate"]); err == nil && br > 0 {
+		info.BitrateKbps = br
+	}
+
+	// Radio streams: Title carries the ICY StreamTitle ("Artist - Song") and
+	// Name the station.
+	if isMPDStream(info.URL) {
+		info.IsLive = true
+		if info.Artist == "" {
+			if artist, title, ok := strings.Cut(info.Title, " - "); ok && artist != "" && title != "" {
+				info.Artist, info.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+				info.Artists = nonEmpty(info.Artist)
+			}
+		}
+		if info.Title == "" {
+			info.Title = song["Name"]
+		} else if info.Album == "" {
+			info.Album = song["Name"]
+		}
+	}
 
 	if elapsedStr, ok := status["elapsed"]; ok {
 		if elapsed, err := strconv.ParseFloat(elapsedStr, 64); err == nil {
 			info.PositionMillis = int64(elapsed * 1000)
+			info.PositionUpdatedAt = time.Now().UnixMilli()
 		}
 	}
 	if durStr, ok := status["duration"]; ok {
@@ -1078,6 +1351,44 @@ func mpdToPlayerInfo(status, song mpd.Attrs) playerInfo {
 	return info
 }
 
+// mpdTagNumber parses Track/Disc tags, which may be "3" or "3/12".
+func mpdTagNumber(tag string) int {
+	head, _, _ := strings.Cut(strings.TrimSpace(tag), "/")
+	n, err := strconv.Atoi(head)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isMPDStream reports whether an MPD queue entry is a network stream rather
+// than a file in the music directory.
+func isMPDStream(uri string) bool {
+	lower := strings.ToLower(uri)
+	for _, scheme := range []string{"http://", "https://", "mms://", "rtsp://", "rtmp://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
 // mpdPlayPause toggles MPD playback based on the current state.
 func mpdPlayPause(currentStatus string) error {
 	c, err := mpd.Dial("tcp", mpdAddr)
@@ -1151,7 +1462,7 @@ func startMPDListener(ctx context.Context, hub *wsHub) {
 // runMPDWatcher creates one MPD idle subscription and runs until error or
 // context cancellation.
 func runMPDWatcher(ctx context.Context, hub *wsHub) error {
-	w, err := mpd.NewWatcher("tcp", mpdAddr, "", "player")
+	w, err := mpd.NewWatcher("tcp", mpdAddr, "", "player", "playlist")
 	if err != nil {
 		return fmt.Errorf("connect: %w", err)
 	}
@@ -1166,10 +1477,13 @@ func runMPDWatcher(ctx context.Context, hub *wsHub) error {
 				return fmt.Errorf("watcher error channel closed")
 			}
 			return fmt.Errorf("watcher: %w", err)
-		case _, ok := <-w.Event:
+		case subsystem, ok := <-w.Event:
 			if !ok {
 				return fmt.Errorf("watcher event channel closed")
 			}
+			if subsystem == "playlist" {
+				hub.queueEvent(trackListEvent{Event: "tracklist_changed", Signal: "playlist", BusName: "mpd"})
+			}
 			hub.requestBroadcast()
 		}
 	}
@@ -1194,17 +1508,27 @@ func mimeToExt(mimeType string) string {
 // cacheMPDPicture writes raw image bytes to artCacheDir and returns the /art/ proxy path.
 // The cache key is SHA1 of "mpd:"+songURI so the same song is never re-fetched.
 func cacheMPDPicture(data []byte, songURI string) (string, error) {
+	cacheName, err := cacheArtBytes("mpd:"+songURI, data)
+	if err != nil {
+		return "", err
+	}
+	return "/art/" + cacheName, nil
+}
+
+// cacheArtBytes writes in-memory image bytes to artCacheDir under SHA1(key) and
+// returns the cache file name. Existing entries are reused as-is.
+func cacheArtBytes(key string, data []byte) (string, error) {
 	mimeType := http.DetectContentType(data)
 	ext := mimeToExt(mimeType)
 
 	h := sha1.New()
-	io.WriteString(h, "mpd:"+songURI)
+	io.WriteString(h, key)
 	cacheName := fmt.Sprintf("%x", h.Sum(nil)) + ext
 	dest := filepath.Join(artCacheDir, cacheName)
 
 	// Already cached — no need to re-write.
 	if _, err := os.Stat(dest); err == nil {
-		return "/art/" + cacheName, nil
+		return cacheName, nil
 	}
 
 	// Atomic write via temp file.
@@ -1216,68 +1540,166 @@ func cacheMPDPicture(data []byte, songURI string) (string, error) {
 		os.Remove(tmp)
 		return "", err
 	}
-	return "/art/" + cacheName, nil
+	return cacheName, nil
 }
 
-var mbArtCache = struct {
-	mu    sync.RWMutex
-	store map[string]tmdbCacheEntry
-}{store: make(map[string]tmdbCacheEntry)}
+var mbArtCache = newLookupCache(12 * time.Hour)
+
+func musicBrainzCacheKey(artist, album string) string {
+	return strings.ToLower(artist + "|" + album)
+}
 
 // musicBrainzArtURL returns a Cover Art Archive image URL for the given artist
-// and album. Results are cached for 12 hours. Returns "" if not found.
+// and album. Results are cached for 12 hours (failed requests for
+// lookupRetryAfter). Returns "" if not found.
 func musicBrainzArtURL(ctx context.Context, artist, album string) string {
-	key := strings.ToLower(artist + "|" + album)
-	now := time.Now()
-
-	mbArtCache.mu.RLock()
-	if e, ok := mbArtCache.store[key]; ok && now.Sub(e.StoredAt) < 12*time.Hour {
-		mbArtCache.mu.RUnlock()
+	key := musicBrainzCacheKey(artist, album)
+	if e, ok := mbArtCache.Get(key); ok {
 		return e.URL
 	}
-	mbArtCache.mu.RUnlock()
-
-	artURL := fetchMusicBrainzArt(ctx, artist, album)
 
-	mbArtCache.mu.Lock()
-	mbArtCache.store[key] = tmdbCacheEntry{URL: artURL, StoredAt: now, Errored: artURL == ""}
-	mbArtCache.mu.Unlock()
+	artURL, err := fetchMusicBrainzArt(ctx, artist, album)
+	if err != nil {
+		log.Printf("warn: musicbrainz lookup %q/%q: %v", artist, album, err)
+	}
+	mbArtCache.Set(key, tmdbCacheEntry{URL: artURL, StoredAt: time.Now(), Errored: artURL == "", Transient: err != nil})
 	return artURL
 }
 
-// fetchMusicBrainzArt queries the MusicBrainz API for a release matching the
-// given artist and album, then returns a Cover Art Archive front image URL.
-func fetchMusicBrainzArt(ctx context.Context, artist, album string) string {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+const (
+	// mbMinScore is the lowest MusicBrainz search score (0–100) we accept as a match.
+	mbMinScore = 90
+	// mbMaxCandidates bounds how many matching releases we probe on Cover Art Archive.
+	mbMaxCandidates = 3
+)
+
+// mbLimiter enforces MusicBrainz's one-request-per-second rule process-wide.
+var mbLimiter = newRateLimiter(time.Second)
+
+// fetchMusicBrainzArt queries the MusicBrainz API for releases matching the
+// given artist and album, then returns the first Cover Art Archive front image
+// that actually exists: the release's own art, else its release group's. An
+// error means a request failed, not that nothing was found.
+func fetchMusicBrainzArt(ctx context.Context, artist, album string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	query := url.QueryEscape(fmt.Sprintf(`artist:"%s" AND release:"%s"`, artist, album))
-	apiURL := "https://musicbrainz.org/ws/2/release/?query=" + query + "&fmt=json&limit=5"
+	query := url.QueryEscape(fmt.Sprintf(`artist:"%s" AND release:"%s"`, luceneEscape(artist), luceneEscape(album)))
+	apiURL := outbound.Endpoints.MusicBrainz + "/release/?query=" + query + "&fmt=json&limit=5"
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
-	if err != nil {
-		return ""
+	if err := mbLimiter.Wait(ctx); err != nil {
+		return "", err
 	}
-	req.Header.Set("User-Agent", "UMR-remoted/1.0 (github.com/ozdotdotdot/UMR)")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ""
+	resp, err := outboundRequest(ctx, http.MethodGet, apiURL)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("musicbrainz status %d", resp.StatusCode)
+	}
 
 	var result struct {
 		Releases []struct {
-			ID    string `json:"id"`
-			Score int    `json:"score"`
+			ID           string `json:"id"`
+			Score        int    `json:"score"`
+			ReleaseGroup struct {
+				ID string `json:"id"`
+			} `json:"release-group"`
 		} `json:"releases"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || len(result.Releases) == 0 {
-		return ""
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	triedGroups := make(map[string]bool)
+	candidates := 0
+	for _, rel := range result.Releases {
+		if rel.Score < mbMinScore || candidates >= mbMaxCandidates {
+			break
+		}
+		candidates++
+		u := fmt.Sprintf("%s/release/%s/front-500", outbound.Endpoints.CoverArtArchive, rel.ID)
+		if ok, err := coverArtExists(ctx, u); err != nil {
+			return "", err
+		} else if ok {
+			return u, nil
+		}
+		if gid := rel.ReleaseGroup.ID; gid != "" && !triedGroups[gid] {
+			triedGroups[gid] = true
+			u := fmt.Sprintf("%s/release-group/%s/front-500", outbound.Endpoints.CoverArtArchive, gid)
+			if ok, err := coverArtExists(ctx, u); err != nil {
+				return "", err
+			} else if ok {
+				return u, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// coverArtExists HEADs a Cover Art Archive URL (following its redirect to the
+// image host) so we never hand clients a URL that 404s. A failed request or a
+// server error is returned as an error rather than as "no art".
+func coverArtExists(ctx context.Context, artURL string) (bool, error) {
+	resp, err := outboundRequest(ctx, http.MethodHead, artURL)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return false, fmt.Errorf("cover art archive status %d", resp.StatusCode)
+	}
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// luceneEscape backslash-escapes Lucene query syntax so artist/album names
+// containing quotes, colons, slashes etc. stay inside their quoted phrase.
+func luceneEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`+-&|!(){}[]^"~*?:\/`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
 	}
+	return b.String()
+}
 
-	mbid := result.Releases[0].ID
-	return fmt.Sprintf("https://coverartarchive.org/release/%s/front-500", mbid)
+// rateLimiter hands out at most one slot per interval, in call order.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+// Wait blocks until the caller's slot comes up or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -1345,8 +1767,43 @@ func populateMetadata(info *playerInfo, meta dbus.Variant) {
 		}
 	}
 	if artist, ok := raw["xesam:artist"]; ok {
+		info.Artists = asStrings(artist)
 		info.Artist = firstString(artist)
 	}
+	if v, ok := raw["xesam:albumArtist"]; ok {
+		info.AlbumArtists = asStrings(v)
+	}
+	if v, ok := raw["xesam:composer"]; ok {
+		info.Composers = asStrings(v)
+	}
+	if v, ok := raw["xesam:genre"]; ok {
+		info.Genres = asStrings(v)
+	}
+	if v, ok := raw["xesam:trackNumber"]; ok {
+		info.TrackNumber = int(asInt64(v))
+	}
+	if v, ok := raw["xesam:discNumber"]; ok {
+		info.DiscNumber = int(asInt64(v))
+	}
+	if v, ok := raw["xesam:contentCreated"]; ok {
+		info.Year = yearOf(asString(v))
+	}
+	if v, ok := raw["xesam:userRating"]; ok {
+		if rating, ok := v.Value().(float64); ok {
+			info.UserRating = &rating
+		}
+	}
+	if v, ok := raw["xesam:useCount"]; ok {
+		info.UseCount = int(asInt64(v))
+	}
+	// Not in the MPRIS spec, but some players export it (bits/s or kbit/s).
+	if v, ok := raw["xesam:audioBitrate"]; ok {
+		if br := asInt64(v); br >= 10000 {
+			info.BitrateKbps = int(br / 1000)
+		} else {
+			info.BitrateKbps = int(br)
+		}
+	}
 	if url, ok := raw["xesam:url"]; ok {
 		info.URL = asString(url)
 	}
@@ -1358,6 +1815,31 @@ func populateMetadata(info *playerInfo, meta dbus.Variant) {
 	}
 }
 
+// asStrings returns the non-empty strings of an "as" metadata value; a plain
+// string (some players send one) becomes a one-element list.
+func asStrings(v dbus.Variant) []string {
+	var out []string
+	switch val := v.Value().(type) {
+	case string:
+		if val != "" {
+			out = append(out, val)
+		}
+	case []string:
+		for _, s := range val {
+			if s != "" {
+				out = append(out, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
 func firstString(v dbus.Variant) string {
 	switch val := v.Value().(type) {
 	case []string:
@@ -1586,12 +2068,13 @@ func setLastPlayer(busName string) {
 	lastPlayerMu.Lock()
 	defer lastPlayerMu.Unlock()
 	lastPlayer = busName
+	lastPlayerAt = time.Now()
 }
 
-func getLastPlayer() string {
+func getLastPlayer() (string, time.Time) {
 	lastPlayerMu.RLock()
 	defer lastPlayerMu.RUnlock()
-	return lastPlayer
+	return lastPlayer, lastPlayerAt
 }
 
 func defaultArtCacheDir() string {
@@ -1610,8 +2093,8 @@ func proxyArtURL(artURL string) string {
 		return ""
 	}
 
-	srcPath := filepath.Clean(u.Path)
-	if !isPathAllowed(srcPath) {
+	srcPath, ok := isPathAllowed(filepath.Clean(u.Path))
+	if !ok {
 		return ""
 	}
 
@@ -1623,11 +2106,22 @@ func proxyArtURL(artURL string) string {
 	return "/art/" + cacheName
 }
 
+// cacheArt copies srcPath into the art cache. srcPath must already be
+// resolved and checked (see pathWithinRoots); it is opened once and
+// everything, including the cache key, comes from that handle.
 func cacheArt(srcPath string) (string, error) {
-	stat, err := os.Stat(srcPath)
+	src, err := os.Open(srcPath)
 	if err != nil {
 		return "", err
 	}
+	defer src.Close()
+	stat, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", srcPath)
+	}
 
 	hash := sha1.New()
 	_, _ = io.WriteString(hash, srcPath)
@@ -1648,12 +2142,6 @@ func cacheArt(srcPath string) (string, error) {
 		}
 	}
 
-	src, err := os.Open(srcPath)
-	if err != nil {
-		return "", err
-	}
-	defer src.Close()
-
 	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
 		return "", err
 	}
@@ -1681,14 +2169,10 @@ func cacheArt(srcPath string) (string, error) {
 	return cacheName, nil
 }
 
-func isPathAllowed(p string) bool {
-	allowed := []string{"/tmp", "/var/tmp"}
-	for _, prefix := range allowed {
-		if strings.HasPrefix(p, prefix) {
-			return true
-		}
-	}
-	return false
+// isPathAllowed resolves a file:// art path (symlinks included) and reports
+// whether it lies inside the configured art roots. Use the returned path.
+func isPathAllowed(p string) (string, bool) {
+	return pathWithinRoots(p, artRoots)
 }
 
 func artHandler(w http.ResponseWriter, r *http.Request) {
@@ -1707,7 +2191,22 @@ func artHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	http.ServeFile(w, r, path)
+
+	// Optional ?w= downscale and Accept-driven JPEG re-encode; variants are cached on disk.
+	serve, err := artVariant(path, parseArtWidth(r), acceptsJPEG(r))
+	if err != nil {
+		log.Printf("warn: art variant for %s: %v", name, err)
+		serve = path
+	}
+	stat, err := os.Stat(serve)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", artETag(serve, stat))
+	w.Header().Set("Cache-Control", artCacheControl)
+	w.Header().Set("Vary", "Accept")
+	http.ServeFile(w, r, serve)
 }
 
 func uiHandler(w http.ResponseWriter, r *http.Request) {
@@ -1754,102 +2253,6 @@ func extractToken(r *http.Request) string {
 	return ""
 }
 
-func isHBO(info playerInfo) bool {
-	urlLower := strings.ToLower(info.URL)
-	if strings.Contains(urlLower, "play.hbomax.com") || strings.Contains(urlLower, "max.com") {
-		return true
-	}
-	id := strings.ToLower(info.Identity)
-	return strings.Contains(id, "hbo") || strings.Contains(id, "max")
-}
-
-func isCrunchyroll(info playerInfo) bool {
-	titleLower := strings.ToLower(info.Title)
-	return strings.HasSuffix(titleLower, " - watch on crunchyroll")
-}
-
-// parseCrunchyrollTitle attempts to extract the show name from Crunchyroll's title format.
-// Returns empty string if title starts with "Season" (no useful show name) or if parsing fails.
-func parseCrunchyrollTitle(title string) string {
-	title = strings.TrimSpace(title)
-	if title == "" {
-		return ""
-	}
-
-	// Remove the " - Watch on Crunchyroll" suffix (case-insensitive)
-	lowerTitle := strings.ToLower(title)
-	suffix := " - watch on crunchyroll"
-	if strings.HasSuffix(lowerTitle, suffix) {
-		title = title[:len(title)-len(suffix)]
-	}
-
-	title = strings.TrimSpace(title)
-
-	// If it starts with "Season", we know there's no useful title
-	if strings.HasPrefix(title, "Season ") {
-		return ""
-	}
-
-	// Common patterns in Crunchyroll titles:
-	// 1. "ShowName (English Dub) Episode Title"
-	// 2. "ShowName Season N Episode Title"
-	// 3. "ARC (numbers) Episode Title"
-
-	// Strategy: Take everything before common episode indicators
-	// Look for patterns like: "(English Dub)", "Season N", or episode numbers/names
-
-	// First, try to find parenthetical content like "(English Dub)" or arc numbers "(892-1088)"
-	if idx := strings.Index(title, " ("); idx > 0 {
-		beforeParen := strings.TrimSpace(title[:idx])
-		insideParen := ""
-		if endIdx := strings.Index(title[idx:], ")"); endIdx > 0 {
-			insideParen = title[idx+2 : idx+endIdx]
-		}
-
-		// Check if inside parentheses looks like arc numbers (contains digits and dashes)
-		// If so, this is probably not the show name
-		hasDigits := false
-		for _, r := range insideParen {
-			if unicode.IsDigit(r) {
-				hasDigits = true
-				break
-			}
-		}
-
-		// If parentheses contain "Dub" or "Sub", the part before is likely the show name
-		if strings.Contains(strings.ToLower(insideParen), "dub") || strings.Contains(strings.ToLower(insideParen), "sub") {
-			if beforeParen != "" {
-				return beforeParen
-			}
-		}
-
-		// If parentheses contain numbers/dashes (like arc numbers), skip the whole thing
-		// and continue parsing what comes after
-		if hasDigits && strings.Contains(insideParen, "-") {
-			// This is likely an arc indicator like "WANO KUNI (892-1088)"
-			// In this case, we can't reliably extract show name, return empty
-			return ""
-		}
-	}
-
-	// Look for " Season " pattern
-	if idx := strings.Index(title, " Season "); idx > 0 {
-		showName := strings.TrimSpace(title[:idx])
-		if showName != "" {
-			return showName
-		}
-	}
-
-	// If we found nothing specific, take the first few words (max 4) as a heuristic
-	// This handles cases where the format is unexpected
-	words := strings.Fields(title)
-	if len(words) > 4 {
-		return strings.Join(words[:4], " ")
-	}
-
-	return title
-}
-
 func tmdbLookup(ctx context.Context, title string) string {
 	title = strings.TrimSpace(title)
 	if title == "" {
@@ -1857,31 +2260,27 @@ func tmdbLookup(ctx context.Context, title string) string {
 	}
 
 	cacheKey := strings.ToLower(title)
-	now := time.Now()
-
-	// cache read
-	tmdbCache.mu.RLock()
-	if entry, ok := tmdbCache.store[cacheKey]; ok {
-		if now.Sub(entry.StoredAt) < 12*time.Hour {
-			if entry.Errored {
-				tmdbCache.mu.RUnlock()
-				return ""
-			}
-			tmdbCache.mu.RUnlock()
-			return entry.URL
+	if entry, ok := tmdbCache.Get(cacheKey); ok {
+		if entry.Errored {
+			return ""
 		}
+		return entry.URL
 	}
-	tmdbCache.mu.RUnlock()
 
-	art, err := tmdbSearchTitle(ctx, title)
-	tmdbCache.mu.Lock()
-	tmdbCache.store[cacheKey] = tmdbCacheEntry{
-		URL:      art,
-		StoredAt: now,
-		Errored:  err != nil || art == "",
+	details, err := tmdbSearchTitle(ctx, title)
+	entry := tmdbCacheEntry{
+		StoredAt:  time.Now(),
+		Errored:   err != nil,
+		Transient: err != nil && !errors.Is(err, errLookupNoMatch),
+		Provider:  "tmdb",
+		QueryName: title,
+	}
+	if err == nil {
+		entry.TMDb = &details
+		entry.URL = tmdbImageURL(tmdbImages.PosterSize, details.PosterPath)
 	}
-	tmdbCache.mu.Unlock()
-	return art
+	tmdbCache.Set(cacheKey, entry)
+	return tmdbPosterURL(entry)
 }
 
 func normalizeTitle(s string) string {
@@ -1894,49 +2293,42 @@ func normalizeTitle(s string) string {
 	return strings.Join(strings.Fields(clean), " ")
 }
 
-func tmdbSearchTitle(ctx context.Context, query string) (string, error) {
+func tmdbSearchTitle(ctx context.Context, query string) (tmdbDetails, error) {
 	if tmdbKey == "" {
-		return "", fmt.Errorf("tmdb key missing")
+		return tmdbDetails{}, fmt.Errorf("tmdb key missing")
 	}
 	// avoid overly long queries
 	if len(query) > 200 {
 		query = query[:200]
 	}
 
-	client := http.Client{Timeout: 2 * time.Second}
-	u := url.URL{
-		Scheme: "https",
-		Host:   "api.themoviedb.org",
-		Path:   "/3/search/multi",
-	}
-	q := u.Query()
+	q := url.Values{}
 	q.Set("api_key", tmdbKey)
 	q.Set("query", query)
 	q.Set("language", "en-US")
 	q.Set("page", "1")
-	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	resp, err := outboundRequest(ctx, http.MethodGet, outbound.Endpoints.TMDbAPI+"/search/multi?"+q.Encode())
 	if err != nil {
-		return "", err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+		return tmdbDetails{}, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("tmdb status %d", resp.StatusCode)
+		return tmdbDetails{}, fmt.Errorf("tmdb status %d", resp.StatusCode)
 	}
 
 	type tmdbResult struct {
+		ID            int     `json:"id"`
 		MediaType     string  `json:"media_type"`
 		Name          string  `json:"name"`
 		Title         string  `json:"title"`
 		OriginalName  string  `json:"original_name"`
 		OriginalTitle string  `json:"original_title"`
 		PosterPath    string  `json:"poster_path"`
+		BackdropPath  string  `json:"backdrop_path"`
+		FirstAirDate  string  `json:"first_air_date"`
+		ReleaseDate   string  `json:"release_date"`
+		Overview      string  `json:"overview"`
 		Popularity    float64 `json:"popularity"`
 	}
 
@@ -1944,11 +2336,11 @@ func tmdbSearchTitle(ctx context.Context, query string) (string, error) {
 		Results []tmdbResult `json:"results"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return tmdbDetails{}, err
 	}
 
 	if len(result.Results) == 0 {
-		return "", fmt.Errorf("no results")
+		return tmdbDetails{}, errLookupNoMatch
 	}
 
 	needle := normalizeTitle(query)
@@ -1984,9 +2376,22 @@ func tmdbSearchTitle(ctx context.Context, query string) (string, error) {
 	}
 
 	if chosen == nil || chosen.PosterPath == "" {
-		return "", fmt.Errorf("no poster")
-	}
-	return "https://image.tmdb.org/t/p/w342" + chosen.PosterPath, nil
+		return tmdbDetails{}, errLookupNoMatch
+	}
+	details := tmdbDetails{
+		ID:           chosen.ID,
+		MediaType:    chosen.MediaType,
+		Name:         chosen.Name,
+		PosterPath:   chosen.PosterPath,
+		BackdropPath: chosen.BackdropPath,
+		Year:         yearOf(chosen.FirstAirDate),
+		Overview:     chosen.Overview,
+	}
+	if chosen.MediaType == "movie" {
+		details.Name = chosen.Title
+		details.Year = yearOf(chosen.ReleaseDate)
+	}
+	return details, nil
 }
 
 type setPlayerURLRequest struct {
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// POST /player/open hands a link to the desktop: MPRIS OpenUri on a player
+// that declares support for the scheme (and MIME type, when we can guess it),
+// add+play for MPD, or xdg-open for web links nothing else can take.
+
+type openRequest struct {
+	URI    string `json:"uri"`
+	Player string `json:"player,omitempty"`
+}
+
+var errCannotOpen = errors.New("player cannot open this URI")
+
+func openHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req openRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.URI = strings.TrimSpace(req.URI)
+	u, err := url.Parse(req.URI)
+	if err != nil || u.Scheme == "" {
+		http.Error(w, "uri must be an absolute URI", http.StatusBadRequest)
+		return
+	}
+	scheme := strings.ToLower(u.Scheme)
+	isWeb := scheme == "http" || scheme == "https"
+	if scheme == "file" {
+		if _, ok := pathWithinRoots(u.Path, append(append([]string{}, musicRoots...), artRoots...)); !ok {
+			http.Error(w, "file URIs must be under the music or art roots", http.StatusForbidden)
+			return
+		}
+	}
+	if req.Player == "" {
+		req.Player = r.URL.Query().Get("player")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	players, err := listPlayers(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("list players: %v", err), http.StatusInternalServerError)
+		return
+	}
+	candidates, err := openCandidates(ctx, players, req.Player)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(u.Path))
+	var lastErr error
+	for _, p := range candidates {
+		if p.BusName == "mpd" {
+			// MPD only takes web links that look like audio, unless asked for.
+			if isWeb && req.Player == "" && !strings.HasPrefix(mimeType, "audio/") {
+				continue
+			}
+			err = mpdOpen(req.URI)
+		} else {
+			err = mprisOpenURI(ctx, p.BusName, scheme, mimeType, req.URI)
+		}
+		if errors.Is(err, errCannotOpen) {
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			log.Printf("warn: open %s on %s: %v", req.URI, p.BusName, err)
+			continue
+		}
+		setLastPlayer(p.BusName)
+		if globalHub != nil {
+			globalHub.requestBroadcast()
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"player":      p.Identity,
+			"action":      "open",
+			"uri":         req.URI,
+			"opened_with": openedWith(p),
+			"status":      "ok",
+		})
+		return
+	}
+
+	if isWeb {
+		if err := xdgOpen(req.URI); err != nil {
+			http.Error(w, fmt.Sprintf("xdg-open: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"action":      "open",
+			"uri":         req.URI,
+			"opened_with": "xdg-open",
+			"status":      "ok",
+		})
+		return
+	}
+	if lastErr != nil {
+		http.Error(w, fmt.Sprintf("open: %v", lastErr), http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, fmt.Sprintf("no player can open %s URIs", scheme), http.StatusUnprocessableEntity)
+}
+
+// openCandidates orders players to try: only the named one when given,
+// otherwise the auto-selected player first, then the rest.
+func openCandidates(ctx context.Context, players []playerInfo, preferred string) ([]playerInfo, error) {
+	if preferred != "" {
+		for _, p := range players {
+			if playerMatches(p, preferred) {
+				return []playerInfo{p}, nil
+			}
+		}
+		return nil, fmt.Errorf("player %q not found", preferred)
+	}
+	out := make([]playerInfo, 0, len(players))
+	if first, err := pickPlayer(ctx, ""); err == nil {
+		out = append(out, first)
+	}
+	for _, p := range players {
+		if len(out) > 0 && p.BusName == out[0].BusName {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func openedWith(p playerInfo) string {
+	if p.BusName == "mpd" {
+		return "mpd"
+	}
+	return "mpris"
+}
+
+// mprisOpenURI calls OpenUri after checking SupportedUriSchemes and, when the
+// MIME type could be guessed from the extension, SupportedMimeTypes.
+func mprisOpenURI(ctx context.Context, busName, scheme, mimeType, uri string) error {
+	conn, err := sessionBus()
+	if err != nil {
+		return fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	schemesVariant, err := obj.GetProperty("org.mpris.MediaPlayer2.SupportedUriSchemes")
+	if err != nil || !containsFoldValue(asStrings(schemesVariant), scheme) {
+		return errCannotOpen
+	}
+	if mimeType != "" {
+		base, _, _ := strings.Cut(mimeType, ";")
+		if v, err := obj.GetProperty("org.mpris.MediaPlayer2.SupportedMimeTypes"); err == nil {
+			if mimes := asStrings(v); len(mimes) > 0 && !containsFoldValue(mimes, base) {
+				return errCannotOpen
+			}
+		}
+	}
+	return obj.CallWithContext(ctx, "org.mpris.MediaPlayer2.Player.OpenUri", 0, uri).Err
+}
+
+func containsFoldValue(list []string, s string) bool {
+	_, ok := findFold(list, s)
+	return ok
+}
+
+// mpdOpen appends uri to the queue and plays it.
+func mpdOpen(uri string) error {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+
+	id, err := c.AddID(uri, -1)
+	if err != nil {
+		return fmt.Errorf("mpd add: %w", err)
+	}
+	return c.PlayID(id)
+}
+
+// xdgOpen launches the desktop's handler for a web link without waiting for
+// it to exit (browsers may keep the launching process around).
+func xdgOpen(uri string) error {
+	cmd := exec.Command("xdg-open", uri)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// All third-party HTTP (TMDb, MusicBrainz, Cover Art Archive, iTunes, remote
+// artwork for palettes) goes through one client so base URLs, proxy, user
+// agent and the offline switch are configured in a single place.
+
+const defaultUserAgent = "UMR-remoted/1.0 (github.com/ozdotdotdot/UMR)"
+
+var errOffline = errors.New("offline mode: outbound requests disabled")
+
+// outboundEndpoints are API base URLs, overridable to point at a local mirror
+// or a test stand-in. No trailing slash.
+type outboundEndpoints struct {
+	TMDbAPI         string `json:"tmdb_api,omitempty"`
+	TMDbImages      string `json:"tmdb_images,omitempty"`
+	MusicBrainz     string `json:"musicbrainz,omitempty"`
+	CoverArtArchive string `json:"coverartarchive,omitempty"`
+	ITunes          string `json:"itunes,omitempty"`
+}
+
+// outboundConfig is the "outbound" section of the config file.
+type outboundConfig struct {
+	Offline   bool              `json:"offline,omitempty"`
+	Proxy     string            `json:"proxy,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Timeout   string            `json:"timeout,omitempty"`
+	Endpoints outboundEndpoints `json:"endpoints,omitempty"`
+}
+
+func defaultOutboundConfig() outboundConfig {
+	return outboundConfig{
+		UserAgent: defaultUserAgent,
+		Timeout:   "10s",
+		Endpoints: outboundEndpoints{
+			TMDbAPI:         "https://api.themoviedb.org/3",
+			TMDbImages:      "https://image.tmdb.org/t/p",
+			MusicBrainz:     "https://musicbrainz.org/ws/2",
+			CoverArtArchive: "https://coverartarchive.org",
+			ITunes:          "https://itunes.apple.com",
+		},
+	}
+}
+
+var (
+	outbound       = defaultOutboundConfig()
+	outboundClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// configureOutbound merges file config over the defaults and builds the
+// shared client. offlineFlag (from -offline / REMOTED_OFFLINE) forces offline.
+func configureOutbound(override *outboundConfig, offlineFlag bool) error {
+	cfg := defaultOutboundConfig()
+	if override != nil {
+		cfg.Offline = override.Offline
+		if override.Proxy != "" {
+			cfg.Proxy = override.Proxy
+		}
+		if override.UserAgent != "" {
+			cfg.UserAgent = override.UserAgent
+		}
+		if override.Timeout != "" {
+			cfg.Timeout = override.Timeout
+		}
+		e := override.Endpoints
+		for dst, src := range map[*string]string{
+			&cfg.Endpoints.TMDbAPI:         e.TMDbAPI,
+			&cfg.Endpoints.TMDbImages:      e.TMDbImages,
+			&cfg.Endpoints.MusicBrainz:     e.MusicBrainz,
+			&cfg.Endpoints.CoverArtArchive: e.CoverArtArchive,
+			&cfg.Endpoints.ITunes:          e.ITunes,
+		} {
+			if src != "" {
+				*dst = strings.TrimRight(src, "/")
+			}
+		}
+	}
+	cfg.Offline = cfg.Offline || offlineFlag
+
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	outbound = cfg
+	outboundClient = &http.Client{Timeout: timeout, Transport: transport}
+	return nil
+}
+
+// offlineMode reports whether third-party lookups are disabled.
+func offlineMode() bool {
+	return outbound.Offline
+}
+
+// outboundRequest performs a request through the shared client with the
+// shared user agent. It fails fast with errOffline in offline mode.
+func outboundRequest(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	if offlineMode() {
+		return nil, errOffline
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", outbound.UserAgent)
+	return outboundClient.Do(req)
+}
+
+// redactProxy hides credentials embedded in a proxy URL for display.
+func redactProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
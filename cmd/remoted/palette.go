@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// artPalette is a small set of theme colors derived from the current artwork,
+// so every client (web UI, widgets, e-ink) can theme the same way.
+type artPalette struct {
+	Dominant   string `json:"dominant"`
+	Vibrant    string `json:"vibrant"`
+	Muted      string `json:"muted"`
+	Foreground string `json:"foreground"`
+}
+
+type paletteEntry struct {
+	Palette  *artPalette
+	StoredAt time.Time
+	// Transient marks a failed fetch (timeout, 5xx, offline) rather than an
+	// image we couldn't use; it is retried after lookupRetryAfter.
+	Transient bool
+}
+
+// paletteCache maps an art key (cache file name or remote URL) to its palette.
+// A nil Palette records a failed extraction so we don't retry on every update.
+// Entries last paletteTTL, like the lookup caches; expired ones are swept
+// whenever a new palette is stored.
+var paletteCache = struct {
+	mu    sync.RWMutex
+	store map[string]paletteEntry
+}{store: make(map[string]paletteEntry)}
+
+const (
+	paletteSampleWidth = 64
+	paletteTTL         = 12 * time.Hour
+	paletteMaxBytes    = 16 << 20
+)
+
+// paletteForInfo returns the palette for the artwork a client would display.
+// Palettes are computed by the enrichment worker, never on the request path:
+// on a miss this returns nil and the worker's broadcast delivers the palette.
+func paletteForInfo(info playerInfo) *artPalette {
+	if info.ArtURLProxy != "" {
+		name := filepath.Base(strings.TrimPrefix(info.ArtURLProxy, "/art/"))
+		if name == "" || name == "." {
+			return nil
+		}
+		return cachedPalette(name, func(context.Context) (*artPalette, bool, error) {
+			return cachedFilePalette(name)
+		})
+	}
+	u, err := url.Parse(info.ArtURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || offlineMode() {
+		return nil
+	}
+	artURL := info.ArtURL
+	return cachedPalette(artURL, func(ctx context.Context) (*artPalette, bool, error) {
+		return fetchRemotePalette(ctx, artURL)
+	})
+}
+
+// cachedPalette returns the cached palette for key, scheduling compute on the
+// enrichment worker when there is none. compute reports whether a failure is
+// transient.
+func cachedPalette(key string, compute func(ctx context.Context) (*artPalette, bool, error)) *artPalette {
+	paletteCache.mu.RLock()
+	e, ok := paletteCache.store[key]
+	paletteCache.mu.RUnlock()
+	if ok && time.Since(e.StoredAt) < e.ttl() {
+		return e.Palette
+	}
+	enrichment.Go("palette:"+key, func(ctx context.Context) {
+		pal, transient, err := compute(ctx)
+		if err != nil {
+			log.Printf("warn: palette for %s: %v", key, err)
+		}
+		paletteCache.mu.Lock()
+		storePalette(key, paletteEntry{Palette: pal, StoredAt: time.Now(), Transient: transient})
+		paletteCache.mu.Unlock()
+	})
+	return nil
+}
+
+func (e paletteEntry) ttl() time.Duration {
+	if e.Transient {
+		return lookupRetryAfter
+	}
+	return paletteTTL
+}
+
+// storePalette records e under key and drops expired entries. The caller
+// holds paletteCache.mu.
+func storePalette(key string, e paletteEntry) {
+	for k, old := range paletteCache.store {
+		if e.StoredAt.Sub(old.StoredAt) >= old.ttl() {
+			delete(paletteCache.store, k)
+		}
+	}
+	paletteCache.store[key] = e
+}
+
+// cachedFilePalette computes the palette of a file in artCacheDir.
+func cachedFilePalette(name string) (*artPalette, bool, error) {
+	f, err := os.Open(filepath.Join(artCacheDir, name))
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	pal, err := paletteFromReader(f)
+	return pal, false, err
+}
+
+func fetchRemotePalette(ctx context.Context, artURL string) (*artPalette, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := outboundRequest(ctx, http.MethodGet, artURL)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		transient := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, transient, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	pal, err := paletteFromReader(resp.Body)
+	return pal, false, err
+}
+
+// paletteFromReader decodes at most paletteMaxBytes of r. The dimensions are
+// checked against artMaxPixels before the full decode, since cached art can
+// come from untrusted embedded tags.
+func paletteFromReader(r io.Reader) (*artPalette, error) {
+	data, err := io.ReadAll(io.LimitReader(r, paletteMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > paletteMaxBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", paletteMaxBytes)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > artMaxPixels {
+		return nil, fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return extractPalette(img), nil
+}
+
+type paletteBucket struct {
+	r, g, b, n int
+}
+
+func (b paletteBucket) rgb() (uint8, uint8, uint8) {
+	return uint8(b.r / b.n), uint8(b.g / b.n), uint8(b.b / b.n)
+}
+
+// extractPalette quantizes a thumbnail of img into 4-bit-per-channel buckets
+// and picks the most populous, most saturated and least saturated colors.
+func extractPalette(img image.Image) *artPalette {
+	thumb := toRGBA(scaleToWidth(img, paletteSampleWidth))
+
+	buckets := make(map[int]*paletteBucket)
+	for i := 0; i+3 < len(thumb.Pix); i += 4 {
+		if thumb.Pix[i+3] < 128 {
+			continue // ignore mostly transparent pixels
+		}
+		r, g, b := int(thumb.Pix[i]), int(thumb.Pix[i+1]), int(thumb.Pix[i+2])
+		key := (r>>4)<<8 | (g>>4)<<4 | b>>4
+		bk := buckets[key]
+		if bk == nil {
+			bk = &paletteBucket{}
+			buckets[key] = bk
+		}
+		bk.r += r
+		bk.g += g
+		bk.b += b
+		bk.n++
+	}
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	list := make([]paletteBucket, 0, len(buckets))
+	for _, bk := range buckets {
+		list = append(list, *bk)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].n > list[j].n })
+
+	dominant := list[0]
+	vibrant, muted := dominant, dominant
+	var vibrantScore, mutedScore float64
+	for _, bk := range list {
+		h := hslOf(bk.rgb())
+		pop := math.Sqrt(float64(bk.n))
+		if h.s >= 0.35 && h.l >= 0.3 && h.l <= 0.75 {
+			if score := h.s * pop; score > vibrantScore {
+				vibrantScore, vibrant = score, bk
+			}
+		}
+		if h.s < 0.35 && h.l >= 0.2 && h.l <= 0.7 {
+			if score := (1 - h.s) * pop; score > mutedScore {
+				mutedScore, muted = score, bk
+			}
+		}
+	}
+
+	dr, dg, db := dominant.rgb()
+	fg := "#ffffff"
+	if contrastRatio(relLuminance(dr, dg, db), 0) > contrastRatio(relLuminance(dr, dg, db), 1) {
+		fg = "#000000"
+	}
+	return &artPalette{
+		Dominant:   hexColor(dominant.rgb()),
+		Vibrant:    hexColor(vibrant.rgb()),
+		Muted:      hexColor(muted.rgb()),
+		Foreground: fg,
+	}
+}
+
+type hsl struct{ h, s, l float64 }
+
+func hslOf(r8, g8, b8 uint8) hsl {
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	if max == min {
+		return hsl{0, 0, l}
+	}
+	d := max - min
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return hsl{h * 60, s, l}
+}
+
+// relLuminance is the WCAG relative luminance of an sRGB color.
+func relLuminance(r, g, b uint8) float64 {
+	lin := func(c uint8) float64 {
+		v := float64(c) / 255
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(r) + 0.7152*lin(g) + 0.0722*lin(b)
+}
+
+func contrastRatio(l1, l2 float64) float64 {
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+func hexColor(r, g, b uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
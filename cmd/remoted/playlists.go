@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+	"github.com/godbus/dbus/v5"
+)
+
+// Playlists from players implementing org.mpris.MediaPlayer2.Playlists
+// (Spotify, Rhythmbox, …) and MPD's stored playlists.
+
+const (
+	mprisPlaylistsIface      = "org.mpris.MediaPlayer2.Playlists"
+	playlistsDefaultMax      = 100
+	playlistsLimitMax        = 1000
+	playlistsDefaultOrdering = "Alphabetical"
+)
+
+var errNoPlaylists = fmt.Errorf("player does not implement %s", mprisPlaylistsIface)
+
+var errUnknownPlaylist = errors.New("no such playlist")
+
+type playlistEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Icon string `json:"icon,omitempty"`
+}
+
+type playlistsResponse struct {
+	Player    string          `json:"player"`
+	BusName   string          `json:"bus_name"`
+	Active    *playlistEntry  `json:"active,omitempty"`
+	Orderings []string        `json:"orderings,omitempty"`
+	Count     int             `json:"count"`
+	Playlists []playlistEntry `json:"playlists"`
+}
+
+type activatePlaylistRequest struct {
+	ID string `json:"id"`
+}
+
+// mprisPlaylist mirrors the (oss) struct used by the Playlists interface.
+type mprisPlaylist struct {
+	ID   dbus.ObjectPath
+	Name string
+	Icon string
+}
+
+func (p mprisPlaylist) entry() playlistEntry {
+	return playlistEntry{ID: string(p.ID), Name: p.Name, Icon: p.Icon}
+}
+
+// playlistsHandler serves GET /player/playlists.
+func playlistsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := playlistsDefaultMax
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, playlistsLimitMax)
+	}
+	reverse := q.Get("reverse") == "1" || strings.EqualFold(q.Get("reverse"), "true")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, q.Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var resp playlistsResponse
+	if info.BusName == "mpd" {
+		resp, err = mpdPlaylists(limit, reverse)
+	} else {
+		resp, err = mprisPlaylists(ctx, info.BusName, q.Get("order"), limit, reverse)
+	}
+	if err == errNoPlaylists {
+		http.Error(w, fmt.Sprintf("%s: %v", info.Identity, err), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("playlists: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp.Player = info.Identity
+	resp.BusName = info.BusName
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// activatePlaylistHandler serves POST /player/playlists/activate.
+func activatePlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req activatePlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "id required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if info.BusName == "mpd" {
+		err = mpdActivatePlaylist(req.ID)
+	} else {
+		err = mprisActivatePlaylist(ctx, info.BusName, req.ID)
+	}
+	if err == errNoPlaylists {
+		http.Error(w, fmt.Sprintf("%s: %v", info.Identity, err), http.StatusNotImplemented)
+		return
+	}
+	if err == errUnknownPlaylist {
+		http.Error(w, fmt.Sprintf("%q: %v", req.ID, err), http.StatusNotFound)
+		return
+	}
+	if err == errUnknownPlaylist {
+		http.Error(w, fmt.Sprintf("%q: %v", req.ID, err), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("activate playlist: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setLastPlayer(info.BusName)
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"player": info.Identity,
+		"action": "activate_playlist",
+		"id":     req.ID,
+		"status": "ok",
+	})
+}
+
+func mprisPlaylists(ctx context.Context, busName, order string, limit int, reverse bool) (playlistsResponse, error) {
+	conn, err := sessionBus()
+	if err != nil {
+		return playlistsResponse{}, fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	countVariant, err := obj.GetProperty(mprisPlaylistsIface + ".PlaylistCount")
+	if err != nil {
+		return playlistsResponse{}, errNoPlaylists
+	}
+	resp := playlistsResponse{Count: int(asInt64(countVariant)), Playlists: []playlistEntry{}}
+	if v, err := obj.GetProperty(mprisPlaylistsIface + ".Orderings"); err == nil {
+		resp.Orderings = asStrings(v)
+	}
+	if order == "" {
+		order = playlistsDefaultOrdering
+	}
+	if len(resp.Orderings) > 0 {
+		canonical, ok := findFold(resp.Orderings, order)
+		if !ok {
+			return playlistsResponse{}, fmt.Errorf("order %q not supported (player offers %s)", order, strings.Join(resp.Orderings, ", "))
+		}
+		order = canonical
+	}
+
+	if v, err := obj.GetProperty(mprisPlaylistsIface + ".ActivePlaylist"); err == nil {
+		var active struct {
+			Valid    bool
+			Playlist mprisPlaylist
+		}
+		if v.Store(&active) == nil && active.Valid {
+			e := active.Playlist.entry()
+			resp.Active = &e
+		}
+	}
+
+	var lists []mprisPlaylist
+	call := obj.CallWithContext(ctx, mprisPlaylistsIface+".GetPlaylists", 0, uint32(0), uint32(limit), order, reverse)
+	if call.Err != nil {
+		return playlistsResponse{}, fmt.Errorf("GetPlaylists: %w", call.Err)
+	}
+	if err := call.Store(&lists); err != nil {
+		return playlistsResponse{}, fmt.Errorf("GetPlaylists: %w", err)
+	}
+	for _, p := range lists {
+		resp.Playlists = append(resp.Playlists, p.entry())
+	}
+	return resp, nil
+}
+
+func mprisActivatePlaylist(ctx context.Context, busName, id string) error {
+	conn, err := sessionBus()
+	if err != nil {
+		return fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	if _, err := obj.GetProperty(mprisPlaylistsIface + ".PlaylistCount"); err != nil {
+		return errNoPlaylists
+	}
+	return obj.CallWithContext(ctx, mprisPlaylistsIface+".ActivatePlaylist", 0, dbus.ObjectPath(id)).Err
+}
+
+// findFold returns the entry of list equal to s ignoring case.
+func findFold(list []string, s string) (string, bool) {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// mpdPlaylists lists MPD's stored playlists by name; the name is the id.
+func mpdPlaylists(limit int, reverse bool) (playlistsResponse, error) {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return playlistsResponse{}, fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+
+	attrs, err := c.ListPlaylists()
+	if err != nil {
+		return playlistsResponse{}, fmt.Errorf("mpd listplaylists: %w", err)
+	}
+	lists := make([]playlistEntry, 0, len(attrs))
+	for _, a := range attrs {
+		if name := a["playlist"]; name != "" {
+			lists = append(lists, playlistEntry{ID: name, Name: name})
+		}
+	}
+	sortPlaylists(lists, reverse)
+	resp := playlistsResponse{Count: len(lists), Orderings: []string{playlistsDefaultOrdering}}
+	if len(lists) > limit {
+		lists = lists[:limit]
+	}
+	resp.Playlists = lists
+	return resp, nil
+}
+
+func sortPlaylists(lists []playlistEntry, reverse bool) {
+	sort.Slice(lists, func(i, j int) bool {
+		a, b := strings.ToLower(lists[i].Name), strings.ToLower(lists[j].Name)
+		if reverse {
+			return a > b
+		}
+		return a < b
+	})
+}
+
+// mpdActivatePlaylist replaces the queue with a stored playlist and plays it,
+// which is what ActivatePlaylist means for MPRIS players. The name is checked
+// first so a typo can't leave the user with an empty queue.
+func mpdActivatePlaylist(name string) error {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+
+	attrs, err := c.ListPlaylists()
+	if err != nil {
+		return fmt.Errorf("mpd listplaylists: %w", err)
+	}
+	found := false
+	for _, a := range attrs {
+		if a["playlist"] == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errUnknownPlaylist
+	}
+	if err := c.Clear(); err != nil {
+		return fmt.Errorf("mpd clear: %w", err)
+	}
+	if err := c.PlaylistLoad(name, -1, -1); err != nil {
+		return fmt.Errorf("mpd load %q: %w", name, err)
+	}
+	return c.Play(0)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Conditional commands. Control and seek endpoints accept optional
+// preconditions (?if_track_id=, ?if_status=, or an If-Match header carrying
+// the player's etag); when the current playerInfo no longer matches they
+// answer 412 without acting, so two simultaneous "next" taps or a seek from a
+// stale snapshot can't skip or land on the wrong track.
+
+type preconditions struct {
+	TrackID string
+	Status  string
+	ETags   []string // from If-Match; "*" matches any player
+}
+
+// playerETag identifies what a command acts on: the player, its track and its
+// playback status. Position is left out so the tag is stable while playing.
+func playerETag(info playerInfo) string {
+	sum := sha1.Sum([]byte(info.BusName + "\x00" + info.TrackID + "\x00" + info.Title + "\x00" + info.PlaybackStatus))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// preconditionsFrom reads the query parameters and If-Match header.
+func preconditionsFrom(r *http.Request) preconditions {
+	q := r.URL.Query()
+	p := preconditions{TrackID: q.Get("if_track_id"), Status: q.Get("if_status")}
+	if h := r.Header.Get("If-Match"); h != "" {
+		for _, tag := range strings.Split(h, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag != "" {
+				p.ETags = append(p.ETags, tag)
+			}
+		}
+	}
+	return p
+}
+
+// with overlays body-level preconditions (e.g. from a seek request).
+func (p preconditions) with(trackID, status string) preconditions {
+	if trackID != "" {
+		p.TrackID = trackID
+	}
+	if status != "" {
+		p.Status = status
+	}
+	return p
+}
+
+// check reports why info fails the preconditions, or nil.
+func (p preconditions) check(info playerInfo) error {
+	if p.TrackID != "" && p.TrackID != info.TrackID {
+		return fmt.Errorf("precondition failed: track is %q, not %q", info.TrackID, p.TrackID)
+	}
+	if p.Status != "" && !strings.EqualFold(p.Status, info.PlaybackStatus) {
+		return fmt.Errorf("precondition failed: status is %s, not %s", info.PlaybackStatus, p.Status)
+	}
+	if len(p.ETags) > 0 {
+		current := playerETag(info)
+		for _, tag := range p.ETags {
+			if tag == "*" || tag == current {
+				return nil
+			}
+		}
+		return fmt.Errorf("precondition failed: player state changed (etag %s)", current)
+	}
+	return nil
+}
+
+// requirePreconditions writes a 412 and returns false when info fails p.
+func requirePreconditions(w http.ResponseWriter, p preconditions, info playerInfo) bool {
+	if err := p.check(info); err != nil {
+		w.Header().Set("ETag", playerETag(info))
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
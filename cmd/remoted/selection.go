@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Auto-selection: which player a command without ?player= acts on, and which
+// one /players reports as is_active. pickPlayer and markActive both go
+// through selectionPolicy.choose so the two can't disagree.
+//
+// Players are ranked by, in order:
+//  1. state: playing, then the last-used player (whatever its state), then
+//     paused, then the rest;
+//  2. the configured priority for the player's identity or bus name;
+//  3. being the last-used player;
+//  4. listing order.
+//
+// With no configuration this is the original heuristic: last player if
+// playing, any playing player, last player, first paused, first.
+
+// selectionConfig is the "selection" section of the config file.
+type selectionConfig struct {
+	// Priorities maps an alias, id, identity or bus name (case-insensitive)
+	// to a priority; higher wins among players in the same state. Default 0.
+	Priorities map[string]int `json:"priorities,omitempty"`
+	// Ignore lists regexes matched against bus name and identity. Matching
+	// players are never auto-selected but can still be targeted by name.
+	Ignore []string `json:"ignore,omitempty"`
+	// StickySeconds bounds how long the last-used player keeps its
+	// preference after it was last commanded or seen playing. 0 = forever.
+	StickySeconds int `json:"sticky_seconds,omitempty"`
+	// IgnoreEmpty skips players reporting no title, artist or URL.
+	IgnoreEmpty bool `json:"ignore_empty,omitempty"`
+}
+
+type selectionPolicy struct {
+	settings    *selectionConfig // as configured, for /config
+	priorities  map[string]int
+	ignore      []*regexp.Regexp
+	sticky      time.Duration
+	ignoreEmpty bool
+}
+
+var selection selectionPolicy
+
+func newSelectionPolicy(cfg *selectionConfig) (selectionPolicy, error) {
+	var p selectionPolicy
+	if cfg == nil {
+		return p, nil
+	}
+	if cfg.StickySeconds < 0 {
+		return p, fmt.Errorf("sticky_seconds must not be negative")
+	}
+	p.settings = cfg
+	p.sticky = time.Duration(cfg.StickySeconds) * time.Second
+	p.ignoreEmpty = cfg.IgnoreEmpty
+	if len(cfg.Priorities) > 0 {
+		p.priorities = make(map[string]int, len(cfg.Priorities))
+		for name, prio := range cfg.Priorities {
+			p.priorities[strings.ToLower(name)] = prio
+		}
+	}
+	for _, pattern := range cfg.Ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return p, fmt.Errorf("ignore %q: %w", pattern, err)
+		}
+		p.ignore = append(p.ignore, re)
+	}
+	return p, nil
+}
+
+// selectable reports whether auto-selection may choose info.
+func (p selectionPolicy) selectable(info playerInfo) bool {
+	for _, re := range p.ignore {
+		if re.MatchString(info.BusName) || re.MatchString(info.Identity) {
+			return false
+		}
+	}
+	if p.ignoreEmpty && info.Title == "" && info.Artist == "" && info.URL == "" {
+		return false
+	}
+	return true
+}
+
+func (p selectionPolicy) priority(info playerInfo) int {
+	for _, name := range []string{info.Alias, info.ID, info.Identity, info.BusName} {
+		if prio, ok := p.priorities[strings.ToLower(name)]; ok && name != "" {
+			return prio
+		}
+	}
+	return 0
+}
+
+// choose returns the index of the player auto-selection picks, or -1 when no
+// player is selectable. last is the last-used player and lastAt when it was
+// recorded.
+func (p selectionPolicy) choose(players []playerInfo, last string, lastAt, now time.Time) int {
+	if last != "" && p.sticky > 0 && now.Sub(lastAt) > p.sticky {
+		last = ""
+	}
+	isLast := func(info playerInfo) bool {
+		return playerMatches(info, last)
+	}
+	tier := func(info playerInfo) int {
+		switch {
+		case strings.EqualFold(info.PlaybackStatus, "Playing"):
+			return 3
+		case isLast(info):
+			return 2
+		case strings.EqualFold(info.PlaybackStatus, "Paused"):
+			return 1
+		}
+		return 0
+	}
+
+	best := -1
+	var bestTier, bestPrio int
+	var bestLast bool
+	for i, info := range players {
+		if !p.selectable(info) {
+			continue
+		}
+		t, prio, l := tier(info), p.priority(info), isLast(info)
+		if best >= 0 {
+			if t != bestTier {
+				if t < bestTier {
+					continue
+				}
+			} else if prio != bestPrio {
+				if prio < bestPrio {
+					continue
+				}
+			} else if !l || bestLast {
+				continue
+			}
+		}
+		best, bestTier, bestPrio, bestLast = i, t, prio, l
+	}
+	return best
+}
+
+// activePlayerIndex applies the configured policy to players.
+func activePlayerIndex(players []playerInfo) int {
+	last, lastAt := getLastPlayer()
+	return selection.choose(players, last, lastAt, time.Now())
+}
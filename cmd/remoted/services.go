@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Streaming services are recognised by declarative rules instead of per-site
+// helpers. A rule matches on the page URL's host, the player identity or the
+// title; the first matching rule names the service, tidies the title, and
+// supplies an icon, theme colors and an art chain for clients and resolveArt.
+// Rules from the config file's "services" section are checked before the
+// built-ins, and a config rule with a built-in's name replaces it.
+
+type serviceTheme struct {
+	Background string `json:"background,omitempty"`
+	Accent     string `json:"accent,omitempty"`
+}
+
+type serviceRule struct {
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"`
+
+	// Match criteria; any one matching is enough. Hosts match the URL host or
+	// any subdomain of it. Identity and Title are regular expressions.
+	URLHosts []string `json:"url_hosts,omitempty"`
+	Identity string   `json:"identity,omitempty"`
+	Title    string   `json:"title,omitempty"`
+
+	// TitleCleanup patterns are removed from the title, in order.
+	TitleCleanup []string      `json:"title_cleanup,omitempty"`
+	Icon         string        `json:"icon,omitempty"`
+	Theme        *serviceTheme `json:"theme,omitempty"`
+	// Art is the provider chain for this service; nil falls back to the
+	// default chain, an empty list disables artwork (the icon is shown).
+	Art []string `json:"art,omitempty"`
+	// Live marks every session of the service as a live stream (no seeking).
+	Live bool `json:"live,omitempty"`
+
+	identityRe *regexp.Regexp
+	titleRe    *regexp.Regexp
+	cleanupRes []*regexp.Regexp
+}
+
+// siteSuffix builds the regex for a trailing " - Site" (or " | Site",
+// " · Watch on Site", …) page-title decoration; names is a regex alternation.
+func siteSuffix(names string) string {
+	return `(?i)\s+[-–—|·•]\s+(?:watch on\s+)?(?:` + names + `)\s*$`
+}
+
+func defaultServiceRules() []serviceRule {
+	return []serviceRule{
+		{
+			Name:         "netflix",
+			Label:        "Netflix",
+			URLHosts:     []string{"netflix.com"},
+			Identity:     `(?i)^netflix$`,
+			Title:        `(?i)^netflix$|` + siteSuffix(`netflix`),
+			TitleCleanup: []string{siteSuffix(`netflix`)},
+			Icon:         "/static/netflix_icon.svg",
+			Theme:        &serviceTheme{Background: "#141414", Accent: "#e50914"},
+			Art:          []string{},
+		},
+		{
+			Name:         "crunchyroll",
+			Label:        "Crunchyroll",
+			URLHosts:     []string{"crunchyroll.com"},
+			Title:        siteSuffix(`crunchyroll`),
+			TitleCleanup: []string{siteSuffix(`crunchyroll`)},
+			Icon:         "/static/crunchyroll_icon.svg",
+			Theme:        &serviceTheme{Background: "#1a0f08", Accent: "#f47521"},
+			Art:          []string{"tmdb"},
+		},
+		{
+			Name:         "hbo",
+			Label:        "HBO Max",
+			URLHosts:     []string{"hbomax.com", "max.com"},
+			Identity:     `(?i)hbo|max`,
+			Title:        siteSuffix(`hbo max`),
+			TitleCleanup: []string{siteSuffix(`hbo max|max`)},
+			Theme:        &serviceTheme{Background: "#0b0b2b", Accent: "#5822b4"},
+			Art:          []string{"player", "tmdb"},
+		},
+		{
+			Name:         "youtube",
+			Label:        "YouTube",
+			URLHosts:     []string{"youtube.com", "youtu.be"},
+			Title:        siteSuffix(`youtube music|youtube`),
+			TitleCleanup: []string{siteSuffix(`youtube music|youtube`)},
+			Theme:        &serviceTheme{Background: "#0f0f0f", Accent: "#ff0000"},
+			Art:          []string{"youtube", "player"},
+		},
+		{
+			Name:         "twitch",
+			Label:        "Twitch",
+			URLHosts:     []string{"twitch.tv"},
+			Title:        siteSuffix(`twitch`),
+			TitleCleanup: []string{siteSuffix(`twitch`)},
+			Theme:        &serviceTheme{Background: "#0e0e10", Accent: "#9146ff"},
+			Live:         true,
+		},
+		// Services recognised only so their title decoration is removed.
+		suffixOnlyRule("disney", "Disney+", "disneyplus.com", `disney\+`),
+		suffixOnlyRule("prime", "Prime Video", "primevideo.com", `(?:amazon )?prime video`),
+		suffixOnlyRule("appletv", "Apple TV+", "tv.apple.com", `apple tv\+?`),
+		suffixOnlyRule("hulu", "Hulu", "hulu.com", `hulu`),
+		suffixOnlyRule("peacock", "Peacock", "peacocktv.com", `peacock`),
+		suffixOnlyRule("paramount", "Paramount+", "paramountplus.com", `paramount\+`),
+		suffixOnlyRule("vimeo", "Vimeo", "vimeo.com", `vimeo`),
+		suffixOnlyRule("dailymotion", "Dailymotion", "dailymotion.com", `dailymotion`),
+		suffixOnlyRule("plex", "Plex", "plex.tv", `plex`),
+		suffixOnlyRule("jellyfin", "Jellyfin", "", `jellyfin`),
+		suffixOnlyRule("emby", "Emby", "", `emby`),
+	}
+}
+
+// suffixOnlyRule is a rule matching a service by host or title suffix and
+// removing that suffix, with the default art chain and no theme.
+func suffixOnlyRule(name, label, host, names string) serviceRule {
+	r := serviceRule{
+		Name:         name,
+		Label:        label,
+		Title:        siteSuffix(names),
+		TitleCleanup: []string{siteSuffix(names)},
+	}
+	if host != "" {
+		r.URLHosts = []string{host}
+	}
+	return r
+}
+
+var serviceRules = mustCompileServiceRules(defaultServiceRules())
+
+func mustCompileServiceRules(rules []serviceRule) []serviceRule {
+	out, err := compileServiceRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func compileServiceRules(rules []serviceRule) ([]serviceRule, error) {
+	out := make([]serviceRule, 0, len(rules))
+	for _, r := range rules {
+		r.Name = strings.ToLower(strings.TrimSpace(r.Name))
+		if r.Name == "" {
+			return nil, fmt.Errorf("service rule without a name")
+		}
+		var err error
+		if r.Identity != "" {
+			if r.identityRe, err = regexp.Compile(r.Identity); err != nil {
+				return nil, fmt.Errorf("service %s: identity: %w", r.Name, err)
+			}
+		}
+		if r.Title != "" {
+			if r.titleRe, err = regexp.Compile(r.Title); err != nil {
+				return nil, fmt.Errorf("service %s: title: %w", r.Name, err)
+			}
+		}
+		r.cleanupRes = nil
+		for _, pat := range r.TitleCleanup {
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: title_cleanup: %w", r.Name, err)
+			}
+			r.cleanupRes = append(r.cleanupRes, re)
+		}
+		for i, h := range r.URLHosts {
+			r.URLHosts[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "www."))
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// mergeServiceRules puts config rules ahead of the built-ins; a config rule
+// named like a built-in replaces it.
+func mergeServiceRules(base, override []serviceRule) ([]serviceRule, error) {
+	extra, err := compileServiceRules(override)
+	if err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]bool, len(extra))
+	for _, r := range extra {
+		replaced[r.Name] = true
+	}
+	out := extra
+	for _, r := range base {
+		if !replaced[r.Name] {
+			out = append(out, r)
+		}
+	}
+	for _, r := range out {
+		for _, name := range r.Art {
+			if lookupArtProvider(name) == nil {
+				log.Printf("warn: unknown art provider %q in service %s", name, r.Name)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r serviceRule) matches(info playerInfo) bool {
+	if host := urlHost(info.URL); host != "" {
+		for _, h := range r.URLHosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return true
+			}
+		}
+	}
+	if r.identityRe != nil && info.Identity != "" && r.identityRe.MatchString(info.Identity) {
+		return true
+	}
+	return r.titleRe != nil && info.Title != "" && r.titleRe.MatchString(info.Title)
+}
+
+func (r serviceRule) cleanTitle(title string) string {
+	for _, re := range r.cleanupRes {
+		title = re.ReplaceAllString(title, "")
+	}
+	return strings.TrimSpace(title)
+}
+
+func urlHost(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func serviceRuleByName(name string) (serviceRule, bool) {
+	for _, r := range serviceRules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return serviceRule{}, false
+}
+
+// applyServiceRule classifies info and applies the matching rule's title
+// cleanup and presentation fields. It returns the service name, or "".
+func applyServiceRule(info *playerInfo) string {
+	for _, r := range serviceRules {
+		if !r.matches(*info) {
+			continue
+		}
+		info.Service = r.Name
+		info.ServiceLabel = r.Label
+		info.ServiceIcon = r.Icon
+		info.ServiceTheme = r.Theme
+		if cleaned := r.cleanTitle(info.Title); cleaned != "" {
+			info.Title = cleaned
+		}
+		return r.Name
+	}
+	return ""
+}
+
+func serviceRuleNames() []string {
+	names := make([]string, 0, len(serviceRules))
+	for _, r := range serviceRules {
+		names = append(names, r.Name)
+	}
+	return names
+}
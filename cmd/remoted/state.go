@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Lookup caches (TMDb, MusicBrainz, iTunes) and Chromium-supplied player URLs
+// are persisted to a JSON file next to the art cache so a restart doesn't
+// re-query every show or lose URLs until the extension posts again. Entries
+// keep their original timestamps, so TTLs carry across restarts.
+
+const (
+	stateFileVersion  = 1
+	stateSaveDebounce = 2 * time.Second
+)
+
+type persistedState struct {
+	Version     int                        `json:"version"`
+	TMDb        map[string]tmdbCacheEntry  `json:"tmdb,omitempty"`
+	MusicBrainz map[string]tmdbCacheEntry  `json:"musicbrainz,omitempty"`
+	ITunes      map[string]tmdbCacheEntry  `json:"itunes,omitempty"`
+	PlayerURLs  map[string]playerURLRecord `json:"player_urls,omitempty"`
+}
+
+type stateStore struct {
+	path  string
+	dirty chan struct{}
+	mu    sync.Mutex // serializes writes
+}
+
+var persistentState = &stateStore{dirty: make(chan struct{}, 1)}
+
+func defaultStatePath(artCache string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(artCache)), "lookups.json")
+}
+
+// markStateDirty schedules a save. Safe to call before the store is configured.
+func markStateDirty() {
+	select {
+	case persistentState.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// load restores the caches from disk, dropping anything already expired.
+func (s *stateStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	if st.Version != stateFileVersion {
+		log.Printf("warn: ignoring %s (version %d, want %d)", s.path, st.Version, stateFileVersion)
+		return nil
+	}
+	tmdbCache.restore(st.TMDb)
+	mbArtCache.restore(st.MusicBrainz)
+	itunesArtCache.restore(st.ITunes)
+	playerURLs.restore(st.PlayerURLs)
+	return nil
+}
+
+// save writes a snapshot atomically: temp file, fsync, rename, fsync dir.
+func (s *stateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := persistedState{
+		Version:     stateFileVersion,
+		TMDb:        tmdbCache.snapshot(),
+		MusicBrainz: mbArtCache.snapshot(),
+		ITunes:      itunesArtCache.snapshot(),
+		PlayerURLs:  playerURLs.snapshot(),
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, s.path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// run saves shortly after each change until ctx is cancelled. The final save
+// on shutdown is the caller's job (see main).
+func (s *stateStore) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.dirty:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(stateSaveDebounce):
+		}
+		if err := s.save(); err != nil {
+			log.Printf("warn: save %s: %v", s.path, err)
+		}
+	}
+}
+
+func (c *lookupCache) snapshot() map[string]tmdbCacheEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]tmdbCacheEntry, len(c.store))
+	for k, e := range c.store {
+		// Transient misses are only worth retrying in this process.
+		if !e.Transient && time.Since(e.StoredAt) < c.ttl {
+			out[k] = e
+		}
+	}
+	return out
+}
+
+func (c *lookupCache) restore(entries map[string]tmdbCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range entries {
+		if time.Since(e.StoredAt) < c.ttl {
+			c.store[k] = e
+		}
+	}
+}
+
+func (s *playerURLStore) snapshot() map[string]playerURLRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]playerURLRecord, len(s.urls))
+	for k, rec := range s.urls {
+		if time.Since(rec.UpdatedAt) <= playerURLTTL {
+			out[k] = rec
+		}
+	}
+	return out
+}
+
+func (s *playerURLStore) restore(records map[string]playerURLRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, rec := range records {
+		if time.Since(rec.UpdatedAt) <= playerURLTTL {
+			s.urls[k] = rec
+		}
+	}
+}
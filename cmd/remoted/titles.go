@@ -0,0 +1,154 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Browser MPRIS titles are page titles: "Episode 3 - Show - Watch on
+// Crunchyroll", "(2) Some video - YouTube", "Show S01E03 – Pilot". The
+// normalizer strips site decoration and splits episodes into show / season /
+// episode / episode title. The untouched title is kept as raw_title.
+
+// siteSuffixes are trailing browser names some players append to the page
+// title. Service names ("- YouTube", "| Disney+") are removed by the service
+// rules' title_cleanup instead.
+var siteSuffixes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\s+[-–—|]\s+(?:mozilla firefox|google chrome|chromium)\s*$`),
+}
+
+// reNotificationCount matches YouTube's "(3) " unread-notification prefix.
+var reNotificationCount = regexp.MustCompile(`^\(\d+\)\s+`)
+
+var (
+	reSxE           = regexp.MustCompile(`(?i)\bS(\d{1,2})\s*[:.]?\s*E(\d{1,4})\b`)
+	reNxN           = regexp.MustCompile(`\b(\d{1,2})x(\d{1,3})\b`)
+	reSeasonEpisode = regexp.MustCompile(`(?i)\bSeason\s+(\d{1,2})\s*[,:\-–]?\s*(?:Episode|Ep\.?)\s*(\d{1,4})\b`)
+	reEpisodeOnly   = regexp.MustCompile(`(?i)\b(?:Episode|Ep\.?)\s*(\d{1,4})\b`)
+	reSegmentSep    = regexp.MustCompile(`\s+[-–—|]\s+`)
+	// reSeasonOnly is "Show Season 2 Episode Title" with no episode number.
+	reSeasonOnly = regexp.MustCompile(`(?i)(?:^|\s)Season\s+(\d{1,2})\b`)
+	// reDubSub is Crunchyroll's "(English Dub)" / "(Sub)" language tag, which
+	// sits between the show and the episode title.
+	reDubSub = regexp.MustCompile(`(?i)\s*\((?:[a-z-]+ )?(?:dub|sub|dubbed|subbed)\)\s*`)
+	// reArcRange is an arc's episode range, "WANO KUNI (892-1088)": the text
+	// around it names the arc, not the show.
+	reArcRange = regexp.MustCompile(`\(\d+\s*[-–]\s*\d+\)`)
+)
+
+const episodeSeparators = " \t-–—:|·,"
+
+// episodeRef is the show/season/episode split of a player title.
+type episodeRef struct {
+	Show         string
+	Season       int
+	Episode      int
+	EpisodeTitle string
+}
+
+// parseEpisodeMarkers finds "S01E03", "1x03", "Season 1 Episode 3" or
+// "Episode 3" in title. Season is 0 when the title only names an episode.
+// Text before the marker is the show. When the marker leads ("Episode 3 -
+// Title - Show"), segments run from specific to general, so the last one is
+// the show and the rest the episode title.
+func parseEpisodeMarkers(title string) (episodeRef, bool) {
+	for _, re := range []*regexp.Regexp{reSxE, reSeasonEpisode, reNxN, reEpisodeOnly} {
+		m := re.FindStringSubmatchIndex(title)
+		if m == nil {
+			continue
+		}
+		ref := episodeRef{
+			Show:         strings.Trim(title[:m[0]], episodeSeparators),
+			EpisodeTitle: strings.Trim(title[m[1]:], episodeSeparators),
+		}
+		if len(m) >= 6 {
+			ref.Season, _ = strconv.Atoi(title[m[2]:m[3]])
+			ref.Episode, _ = strconv.Atoi(title[m[4]:m[5]])
+		} else {
+			ref.Episode, _ = strconv.Atoi(title[m[2]:m[3]])
+		}
+		if ref.Show == "" && ref.EpisodeTitle != "" {
+			segs := reSegmentSep.Split(ref.EpisodeTitle, -1)
+			if len(segs) > 0 {
+				ref.Show = strings.TrimSpace(segs[len(segs)-1])
+				ref.EpisodeTitle = strings.TrimSpace(strings.Join(segs[:len(segs)-1], " - "))
+			}
+		}
+		return ref, true
+	}
+	return episodeRef{}, false
+}
+
+// normalizedTitle is the result of normalizePageTitle.
+type normalizedTitle struct {
+	Title string // display title without site decoration
+	episodeRef
+}
+
+// stripPageDecoration removes notification counters and browser names from a
+// page title, leaving any service suffix for the service rules.
+func stripPageDecoration(raw string) string {
+	title := strings.TrimSpace(raw)
+	title = reNotificationCount.ReplaceAllString(title, "")
+	for changed := true; changed; {
+		changed = false
+		for _, re := range siteSuffixes {
+			if next := re.ReplaceAllString(title, ""); next != title && next != "" {
+				title, changed = strings.TrimSpace(next), true
+			}
+		}
+	}
+	return title
+}
+
+// normalizePageTitle strips page decoration from a title that has already been
+// through its service rule and, when parseEpisodes is set, extracts episode
+// structure. Without an episode marker, a dub/sub tag or a bare "Season N"
+// still ends the show name.
+func normalizePageTitle(raw string, parseEpisodes bool) normalizedTitle {
+	out := normalizedTitle{Title: stripPageDecoration(raw)}
+	if !parseEpisodes {
+		return out
+	}
+	var dubShow, dubRest string
+	if m := reDubSub.FindStringIndex(out.Title); m != nil && m[0] > 0 {
+		dubShow, dubRest = out.Title[:m[0]], out.Title[m[1]:]
+		out.Title = dubShow
+		if dubRest != "" {
+			out.Title += " - " + dubRest
+		}
+	}
+	if ref, ok := parseEpisodeMarkers(out.Title); ok {
+		out.episodeRef = ref
+		return out
+	}
+	if dubShow != "" {
+		out.Show = dubShow
+		out.EpisodeTitle = strings.Trim(dubRest, episodeSeparators)
+		return out
+	}
+	if m := reSeasonOnly.FindStringSubmatchIndex(out.Title); m != nil {
+		out.Show = strings.Trim(out.Title[:m[0]], episodeSeparators)
+		out.Season, _ = strconv.Atoi(out.Title[m[2]:m[3]])
+		out.EpisodeTitle = strings.Trim(out.Title[m[1]:], episodeSeparators)
+	}
+	return out
+}
+
+// normalizePlayerTitle records the raw title, strips browser decoration, applies
+// the service rule (which removes the site suffix), and fills show/season/episode. Episode parsing is
+// skipped for music (anything with an album) so track names stay intact.
+func normalizePlayerTitle(info *playerInfo) {
+	if info.Title == "" {
+		applyServiceRule(info)
+		return
+	}
+	info.RawTitle = info.Title
+	info.Title = stripPageDecoration(info.Title)
+	applyServiceRule(info)
+
+	n := normalizePageTitle(info.Title, info.Album == "")
+	info.Title = n.Title
+	info.Show, info.Season, info.Episode, info.EpisodeTitle = n.Show, n.Season, n.Episode, n.EpisodeTitle
+}
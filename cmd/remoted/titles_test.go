@@ -0,0 +1,178 @@
+package main
+
+import "testing"
+
+func TestNormalizePlayerTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      playerInfo
+		title   string
+		service string
+		show    string
+		season  int
+		episode int
+		epTitle string
+	}{
+		{
+			name:    "crunchyroll marker first",
+			in:      playerInfo{Identity: "Chromium", Title: "Episode 3 - The Dragon's Flame - Frieren: Beyond Journey's End (English Dub) - Watch on Crunchyroll"},
+			title:   "Episode 3 - The Dragon's Flame - Frieren: Beyond Journey's End",
+			service: "crunchyroll",
+			show:    "Frieren: Beyond Journey's End",
+			episode: 3,
+			epTitle: "The Dragon's Flame",
+		},
+		{
+			name:    "crunchyroll dub tag ends the show",
+			in:      playerInfo{Identity: "Chromium", Title: "One Piece (English Dub) The Great Pirate Era - Watch on Crunchyroll"},
+			title:   "One Piece - The Great Pirate Era",
+			service: "crunchyroll",
+			show:    "One Piece",
+			epTitle: "The Great Pirate Era",
+		},
+		{
+			name:    "crunchyroll season without episode",
+			in:      playerInfo{Identity: "Chromium", Title: "Frieren Season 2 The Journey - Watch on Crunchyroll"},
+			title:   "Frieren Season 2 The Journey",
+			service: "crunchyroll",
+			show:    "Frieren",
+			season:  2,
+			epTitle: "The Journey",
+		},
+		{
+			name:    "crunchyroll season first",
+			in:      playerInfo{Identity: "Chromium", Title: "Season 1 A New Journey - Watch on Crunchyroll"},
+			title:   "Season 1 A New Journey",
+			service: "crunchyroll",
+			season:  1,
+			epTitle: "A New Journey",
+		},
+		{
+			name:    "crunchyroll arc range",
+			in:      playerInfo{Identity: "Chromium", Title: "WANO KUNI (892-1088) The Ninja-Pirate Alliance - Watch on Crunchyroll"},
+			title:   "WANO KUNI (892-1088) The Ninja-Pirate Alliance",
+			service: "crunchyroll",
+		},
+		{
+			name:    "youtube notification counter",
+			in:      playerInfo{Identity: "Mozilla Firefox", Title: "(3) Building a Synth from Scratch - YouTube"},
+			title:   "Building a Synth from Scratch",
+			service: "youtube",
+		},
+		{
+			name:    "youtube with browser suffix",
+			in:      playerInfo{Identity: "Chromium", Title: "Lofi beats - YouTube - Google Chrome"},
+			title:   "Lofi beats",
+			service: "youtube",
+		},
+		{
+			name:    "SxxEyy",
+			in:      playerInfo{Identity: "mpv", Title: "The Bear S01E03 Brigade"},
+			title:   "The Bear S01E03 Brigade",
+			show:    "The Bear",
+			season:  1,
+			episode: 3,
+			epTitle: "Brigade",
+		},
+		{
+			name:    "S1 E3 with service suffix",
+			in:      playerInfo{Identity: "Chromium", Title: "Severance S1 E3 - In Perpetuity | Apple TV+"},
+			title:   "Severance S1 E3 - In Perpetuity",
+			service: "appletv",
+			show:    "Severance",
+			season:  1,
+			episode: 3,
+			epTitle: "In Perpetuity",
+		},
+		{
+			name:    "1x03",
+			in:      playerInfo{Identity: "VLC media player", Title: "Andor - 1x03 - Reckoning"},
+			title:   "Andor - 1x03 - Reckoning",
+			show:    "Andor",
+			season:  1,
+			episode: 3,
+			epTitle: "Reckoning",
+		},
+		{
+			name:    "Season 1, Episode 3",
+			in:      playerInfo{Identity: "Chromium", Title: "The Last of Us Season 1, Episode 3 - HBO Max"},
+			title:   "The Last of Us Season 1, Episode 3",
+			service: "hbo",
+			show:    "The Last of Us",
+			season:  1,
+			episode: 3,
+		},
+		{
+			name:    "Episode 12",
+			in:      playerInfo{Identity: "Chromium", Title: "Bluey Episode 12 | Disney+"},
+			title:   "Bluey Episode 12",
+			service: "disney",
+			show:    "Bluey",
+			episode: 12,
+		},
+		{
+			name:  "music with dash in title",
+			in:    playerInfo{Identity: "Spotify", Title: "Paranoid Android - Remastered", Artist: "Radiohead", Album: "OK Computer"},
+			title: "Paranoid Android - Remastered",
+		},
+		{
+			name:  "music with x between digits",
+			in:    playerInfo{Identity: "Music Player Daemon", Title: "2x4", Artist: "Metallica", Album: "Load"},
+			title: "2x4",
+		},
+		{
+			name:  "music mentioning episode",
+			in:    playerInfo{Identity: "Rhythmbox", Title: "Episode 1 - Live at the BBC", Artist: "Someone", Album: "Sessions"},
+			title: "Episode 1 - Live at the BBC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.in
+			normalizePlayerTitle(&info)
+			if info.RawTitle != tt.in.Title {
+				t.Errorf("raw_title = %q, want %q", info.RawTitle, tt.in.Title)
+			}
+			if info.Title != tt.title {
+				t.Errorf("title = %q, want %q", info.Title, tt.title)
+			}
+			if info.Service != tt.service {
+				t.Errorf("service = %q, want %q", info.Service, tt.service)
+			}
+			if info.Show != tt.show || info.Season != tt.season || info.Episode != tt.episode || info.EpisodeTitle != tt.epTitle {
+				t.Errorf("episode = {%q %d %d %q}, want {%q %d %d %q}",
+					info.Show, info.Season, info.Episode, info.EpisodeTitle,
+					tt.show, tt.season, tt.episode, tt.epTitle)
+			}
+		})
+	}
+}
+
+func TestParseEpisodeMarkersNoMarker(t *testing.T) {
+	for _, title := range []string{"Paranoid Android - Remastered", "Live in 1x Speed", "Sxe Loop"} {
+		if ref, ok := parseEpisodeMarkers(title); ok {
+			t.Errorf("parseEpisodeMarkers(%q) = %+v, want no match", title, ref)
+		}
+	}
+}
+
+func TestTMDbQueryTitle(t *testing.T) {
+	tests := []struct {
+		raw   string
+		query string
+	}{
+		{"One Piece (English Dub) The Great Pirate Era - Watch on Crunchyroll", "One Piece"},
+		{"Frieren Season 2 The Journey - Watch on Crunchyroll", "Frieren"},
+		{"Episode 3 - The Dragon's Flame - Frieren: Beyond Journey's End (English Dub) - Watch on Crunchyroll", "Frieren: Beyond Journey's End"},
+		{"Season 1 A New Journey - Watch on Crunchyroll", ""},
+		{"WANO KUNI (892-1088) The Ninja-Pirate Alliance - Watch on Crunchyroll", ""},
+		{"Dune: Part Two | Netflix", "Dune: Part Two"},
+	}
+	for _, tt := range tests {
+		info := playerInfo{Identity: "Chromium", Title: tt.raw}
+		normalizePlayerTitle(&info)
+		if got := tmdbQueryTitle(info); got != tt.query {
+			t.Errorf("tmdbQueryTitle(%q) = %q, want %q", tt.raw, got, tt.query)
+		}
+	}
+}
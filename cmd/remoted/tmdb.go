@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tmdbDetails is what we keep from a TMDb match. Show/movie searches fill the
+// top half; episode lookups fill EpisodeName/StillPath (and Overview).
+type tmdbDetails struct {
+	ID           int    `json:"id,omitempty"`
+	MediaType    string `json:"media_type,omitempty"`
+	Name         string `json:"name,omitempty"`
+	PosterPath   string `json:"poster_path,omitempty"`
+	BackdropPath string `json:"backdrop_path,omitempty"`
+	Year         int    `json:"year,omitempty"`
+	Overview     string `json:"overview,omitempty"`
+
+	EpisodeName string `json:"episode_name,omitempty"`
+	StillPath   string `json:"still_path,omitempty"`
+}
+
+// tmdbImageConfig is the "tmdb" section of the config file: image sizes as
+// named by TMDb's /configuration endpoint (w92…w780, original, …).
+type tmdbImageConfig struct {
+	PosterSize   string `json:"poster_size,omitempty"`
+	StillSize    string `json:"still_size,omitempty"`
+	BackdropSize string `json:"backdrop_size,omitempty"`
+}
+
+func defaultTMDbImageConfig() tmdbImageConfig {
+	return tmdbImageConfig{PosterSize: "w342", StillSize: "w300", BackdropSize: "w1280"}
+}
+
+var tmdbImages = defaultTMDbImageConfig()
+
+func mergeTMDbImageConfig(override *tmdbImageConfig) tmdbImageConfig {
+	cfg := defaultTMDbImageConfig()
+	if override == nil {
+		return cfg
+	}
+	if override.PosterSize != "" {
+		cfg.PosterSize = override.PosterSize
+	}
+	if override.StillSize != "" {
+		cfg.StillSize = override.StillSize
+	}
+	if override.BackdropSize != "" {
+		cfg.BackdropSize = override.BackdropSize
+	}
+	return cfg
+}
+
+func tmdbImageURL(size, path string) string {
+	if path == "" {
+		return ""
+	}
+	return outbound.Endpoints.TMDbImages + "/" + size + path
+}
+
+// tmdbPosterURL returns the poster for a cached search, honouring the
+// configured size. Entries persisted before details were stored only have URL.
+func tmdbPosterURL(e tmdbCacheEntry) string {
+	if e.Errored {
+		return ""
+	}
+	if e.TMDb != nil && e.TMDb.PosterPath != "" {
+		return tmdbImageURL(tmdbImages.PosterSize, e.TMDb.PosterPath)
+	}
+	return e.URL
+}
+
+func yearOf(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
+
+// tmdbQueryTitle is the string we search TMDb for: the show parsed by the
+// title normalizer, else the title as-is. An episode or season marker with no
+// show name around it, or an arc title such as "WANO KUNI (892-1088)", yields
+// "" (nothing useful to search for).
+func tmdbQueryTitle(info playerInfo) string {
+	if info.Show != "" || info.Episode > 0 || info.Season > 0 {
+		return strings.TrimSpace(info.Show)
+	}
+	if reArcRange.MatchString(info.Title) {
+		return ""
+	}
+	return strings.TrimSpace(info.Title)
+}
+
+// applyTMDbDetails adds the matched show/movie and, for TV, the episode still
+// and metadata to info. Like the art provider it only reads caches and
+// schedules misses on the background worker.
+func applyTMDbDetails(info *playerInfo) {
+	if tmdbKey == "" || offlineMode() {
+		return
+	}
+	query := tmdbQueryTitle(*info)
+	if query == "" {
+		return
+	}
+	key := strings.ToLower(query)
+	e, ok := tmdbCache.Get(key)
+	if !ok {
+		enrichment.Go("tmdb:"+key, func(ctx context.Context) {
+			tmdbLookup(ctx, query)
+		})
+		return
+	}
+	d := e.TMDb
+	if d == nil || e.Errored {
+		return
+	}
+	info.TMDbID = d.ID
+	info.TMDbMediaType = d.MediaType
+	info.Show = d.Name
+	info.BackdropURL = tmdbImageURL(tmdbImages.BackdropSize, d.BackdropPath)
+	info.Year = d.Year
+	info.Overview = d.Overview
+
+	if d.MediaType != "tv" || info.Season <= 0 || info.Episode <= 0 {
+		return
+	}
+	showID, season, episode := d.ID, info.Season, info.Episode
+	epKey := fmt.Sprintf("episode:%d:%d:%d", showID, season, episode)
+	ep, ok := tmdbCache.Get(epKey)
+	if !ok {
+		enrichment.Go("tmdb:"+epKey, func(ctx context.Context) {
+			details, err := tmdbFetchEpisode(ctx, showID, season, episode)
+			entry := tmdbCacheEntry{StoredAt: time.Now(), Errored: err != nil, Transient: err != nil && !errors.Is(err, errLookupNoMatch), Provider: "tmdb"}
+			if err == nil {
+				entry.TMDb = &details
+			}
+			tmdbCache.Set(epKey, entry)
+		})
+		return
+	}
+	if ep.TMDb == nil || ep.Errored {
+		return
+	}
+	info.StillURL = tmdbImageURL(tmdbImages.StillSize, ep.TMDb.StillPath)
+	if ep.TMDb.EpisodeName != "" {
+		info.EpisodeTitle = ep.TMDb.EpisodeName
+	}
+	if ep.TMDb.Overview != "" {
+		info.Overview = ep.TMDb.Overview
+	}
+}
+
+func tmdbFetchEpisode(ctx context.Context, showID, season, episode int) (tmdbDetails, error) {
+	q := url.Values{}
+	q.Set("api_key", tmdbKey)
+	q.Set("language", "en-US")
+	apiURL := fmt.Sprintf("%s/tv/%d/season/%d/episode/%d?%s", outbound.Endpoints.TMDbAPI, showID, season, episode, q.Encode())
+
+	resp, err := outboundRequest(ctx, http.MethodGet, apiURL)
+	if err != nil {
+		return tmdbDetails{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return tmdbDetails{}, errLookupNoMatch
+	}
+	if resp.StatusCode != http.StatusOK {
+		return tmdbDetails{}, fmt.Errorf("tmdb status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Name      string `json:"name"`
+		Overview  string `json:"overview"`
+		StillPath string `json:"still_path"`
+		AirDate   string `json:"air_date"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return tmdbDetails{}, err
+	}
+	return tmdbDetails{
+		ID:          showID,
+		MediaType:   "tv",
+		EpisodeName: result.Name,
+		StillPath:   result.StillPath,
+		Overview:    result.Overview,
+		Year:        yearOf(result.AirDate),
+	}, nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+	"github.com/godbus/dbus/v5"
+)
+
+// "Up next" for players that implement org.mpris.MediaPlayer2.TrackList
+// (VLC, Rhythmbox, Strawberry, …) and for the MPD queue.
+
+const (
+	mprisTrackListIface = "org.mpris.MediaPlayer2.TrackList"
+	trackListDefaultMax = 50
+	trackListLimitMax   = 500
+)
+
+var errNoTrackList = fmt.Errorf("player does not implement %s", mprisTrackListIface)
+
+type trackEntry struct {
+	TrackID      string   `json:"track_id"`
+	Title        string   `json:"title,omitempty"`
+	Artist       string   `json:"artist,omitempty"`
+	Artists      []string `json:"artists,omitempty"`
+	Album        string   `json:"album,omitempty"`
+	LengthMillis int64    `json:"length_millis,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	ArtURL       string   `json:"art_url,omitempty"`
+	ArtURLProxy  string   `json:"art_url_proxy,omitempty"`
+}
+
+type trackListResponse struct {
+	Player         string       `json:"player"`
+	BusName        string       `json:"bus_name"`
+	CurrentTrackID string       `json:"current_track_id,omitempty"`
+	CanEditTracks  bool         `json:"can_edit_tracks"`
+	Tracks         []trackEntry `json:"tracks"`
+}
+
+// trackListEvent is pushed over /ws when a player's track list changes.
+type trackListEvent struct {
+	Event   string `json:"event"`
+	Signal  string `json:"signal"`
+	BusName string `json:"bus_name,omitempty"`
+}
+
+// trackListEventFor describes a TrackList signal. Signals carry the sender's
+// unique connection name, so it is mapped back to the MPRIS bus name through
+// owners (unique name -> MPRIS name).
+func trackListEventFor(owners map[string]string, sig *dbus.Signal) trackListEvent {
+	return trackListEvent{
+		Event:   "tracklist_changed",
+		Signal:  strings.TrimPrefix(sig.Name, mprisTrackListIface+"."),
+		BusName: owners[sig.Sender],
+	}
+}
+
+// mprisOwners maps the unique name of every current MPRIS player to its
+// well-known bus name.
+func mprisOwners(ctx context.Context, conn *dbus.Conn) map[string]string {
+	owners := make(map[string]string)
+	lctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	names, err := listNames(lctx, conn)
+	if err != nil {
+		return owners
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "org.mpris.MediaPlayer2.") {
+			continue
+		}
+		var owner string
+		if err := conn.BusObject().CallWithContext(lctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner); err == nil {
+			owners[owner] = name
+		}
+	}
+	return owners
+}
+
+// trackNameOwner applies a NameOwnerChanged signal to owners.
+func trackNameOwner(owners map[string]string, sig *dbus.Signal) {
+	if len(sig.Body) < 3 {
+		return
+	}
+	name, _ := sig.Body[0].(string)
+	oldOwner, _ := sig.Body[1].(string)
+	newOwner, _ := sig.Body[2].(string)
+	if !strings.HasPrefix(name, "org.mpris.MediaPlayer2.") {
+		return
+	}
+	if oldOwner != "" && owners[oldOwner] == name {
+		delete(owners, oldOwner)
+	}
+	if newOwner != "" {
+		owners[newOwner] = name
+	}
+}
+
+type trackListGoToRequest struct {
+	TrackID string `json:"track_id"`
+}
+
+func trackFromInfo(info playerInfo) trackEntry {
+	return trackEntry{
+		TrackID:      info.TrackID,
+		Title:        info.Title,
+		Artist:       info.Artist,
+		Artists:      info.Artists,
+		Album:        info.Album,
+		LengthMillis: info.LengthMillis,
+		URL:          info.URL,
+		ArtURL:       info.ArtURL,
+		ArtURLProxy:  info.ArtURLProxy,
+	}
+}
+
+// upcomingTracks returns the entries after currentID, or all of them when the
+// current track isn't in the list (or there is none).
+func upcomingTracks(tracks []trackEntry, currentID string, limit int) []trackEntry {
+	for i, t := range tracks {
+		if currentID != "" && t.TrackID == currentID {
+			tracks = tracks[i+1:]
+			break
+		}
+	}
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks
+}
+
+// trackListHandler serves GET /player/tracklist.
+func trackListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := trackListDefaultMax
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, trackListLimitMax)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var resp trackListResponse
+	if info.BusName == "mpd" {
+		resp, err = mpdTrackList(limit)
+	} else {
+		resp, err = mprisTrackList(ctx, info, limit)
+	}
+	if err == errNoTrackList {
+		http.Error(w, fmt.Sprintf("%s: %v", info.Identity, err), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("tracklist: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp.Player = info.Identity
+	resp.BusName = info.BusName
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// trackListGoToHandler serves POST /player/tracklist/goto.
+func trackListGoToHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req trackListGoToRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.TrackID == "" {
+		http.Error(w, "track_id required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
+
+	if info.BusName == "mpd" {
+		id, convErr := strconv.Atoi(req.TrackID)
+		if convErr != nil {
+			http.Error(w, "track_id must be an MPD song id", http.StatusBadRequest)
+			return
+		}
+		err = mpdPlayID(id)
+	} else {
+		err = mprisGoTo(ctx, info.BusName, req.TrackID)
+	}
+	if err == errNoTrackList {
+		http.Error(w, fmt.Sprintf("%s: %v", info.Identity, err), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("goto: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setLastPlayer(info.BusName)
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"player":   info.Identity,
+		"action":   "goto",
+		"track_id": req.TrackID,
+		"status":   "ok",
+	})
+}
+
+func mprisHasTrackList(ctx context.Context, obj dbus.BusObject) bool {
+	var v dbus.Variant
+	err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, mprisRootIface, "HasTrackList").Store(&v)
+	return err == nil && asBool(v)
+}
+
+func mprisTrackList(ctx context.Context, info playerInfo, limit int) (trackListResponse, error) {
+	conn, err := sessionBus()
+	if err != nil {
+		return trackListResponse{}, fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(info.BusName, "/org/mpris/MediaPlayer2")
+	if !mprisHasTrackList(ctx, obj) {
+		return trackListResponse{}, errNoTrackList
+	}
+	props, err := getAllProps(ctx, obj, mprisTrackListIface)
+	if err != nil {
+		return trackListResponse{}, fmt.Errorf("tracks: %w", err)
+	}
+	resp := trackListResponse{CurrentTrackID: info.TrackID, CanEditTracks: asBool(props["CanEditTracks"]), Tracks: []trackEntry{}}
+	ids, _ := props["Tracks"].Value().([]dbus.ObjectPath)
+	if len(ids) == 0 {
+		return resp, nil
+	}
+
+	// Only fetch metadata for what we return.
+	start := 0
+	for i, id := range ids {
+		if string(id) == info.TrackID {
+			start = i + 1
+			break
+		}
+	}
+	ids = ids[start:]
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+
+	var metas []map[string]dbus.Variant
+	call := obj.CallWithContext(ctx, mprisTrackListIface+".GetTracksMetadata", 0, ids)
+	if call.Err != nil {
+		return trackListResponse{}, fmt.Errorf("GetTracksMetadata: %w", call.Err)
+	}
+	if err := call.Store(&metas); err != nil {
+		return trackListResponse{}, fmt.Errorf("GetTracksMetadata: %w", err)
+	}
+	for _, meta := range metas {
+		var t playerInfo
+		populateMetadata(&t, dbus.MakeVariant(meta))
+		resp.Tracks = append(resp.Tracks, trackFromInfo(t))
+	}
+	return resp, nil
+}
+
+func mprisGoTo(ctx context.Context, busName, trackID string) error {
+	conn, err := sessionBus()
+	if err != nil {
+		return fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	if !mprisHasTrackList(ctx, obj) {
+		return errNoTrackList
+	}
+	call := obj.CallWithContext(ctx, mprisTrackListIface+".GoTo", 0, dbus.ObjectPath(trackID))
+	return call.Err
+}
+
+// mpdTrackList returns the MPD queue after the current song. Track IDs are
+// MPD song ids, which POST /player/tracklist/goto accepts.
+func mpdTrackList(limit int) (trackListResponse, error) {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return trackListResponse{}, fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+
+	status, err := c.Status()
+	if err != nil {
+		return trackListResponse{}, fmt.Errorf("mpd status: %w", err)
+	}
+	songs, err := c.PlaylistInfo(-1, -1)
+	if err != nil {
+		return trackListResponse{}, fmt.Errorf("mpd playlistinfo: %w", err)
+	}
+	tracks := make([]trackEntry, 0, len(songs))
+	for _, song := range songs {
+		tracks = append(tracks, trackFromInfo(mpdToPlayerInfo(mpd.Attrs{"songid": song["Id"]}, song)))
+	}
+	return trackListResponse{
+		CurrentTrackID: status["songid"],
+		CanEditTracks:  true,
+		Tracks:         upcomingTracks(tracks, status["songid"], limit),
+	}, nil
+}
+
+func mpdPlayID(id int) error {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+	return c.PlayID(id)
+}
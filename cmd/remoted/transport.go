@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// Explicit, idempotent transport commands. Unlike /player/playpause they never
+// look at the (possibly stale) playback status to decide what to do: "pause"
+// on a paused player is a no-op, not a resume.
+
+// transportActions maps each explicit action to its MPRIS method and the
+// playback status it should produce.
+var transportActions = map[string]struct {
+	Method string
+	Want   string
+}{
+	"play":  {"org.mpris.MediaPlayer2.Player.Play", "Playing"},
+	"pause": {"org.mpris.MediaPlayer2.Player.Pause", "Paused"},
+	"stop":  {"org.mpris.MediaPlayer2.Player.Stop", "Stopped"},
+}
+
+const transportSettleTimeout = 500 * time.Millisecond
+
+func playHandler(w http.ResponseWriter, r *http.Request) {
+	transportHandler(w, r, "play")
+}
+
+func pauseHandler(w http.ResponseWriter, r *http.Request) {
+	transportHandler(w, r, "pause")
+}
+
+func stopHandler(w http.ResponseWriter, r *http.Request) {
+	transportHandler(w, r, "stop")
+}
+
+func transportHandler(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
+	status, err := applyTransport(ctx, info, action)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", action, err), http.StatusInternalServerError)
+		return
+	}
+
+	setLastPlayer(info.BusName)
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"player":          info.Identity,
+		"action":          action,
+		"playback_status": status,
+		"status":          "ok",
+	})
+}
+
+// applyTransport runs action on the player and returns the playback status
+// observed afterwards (waiting briefly for the player to settle).
+func applyTransport(ctx context.Context, info playerInfo, action string) (string, error) {
+	spec, ok := transportActions[action]
+	if !ok {
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+	if info.BusName == "mpd" {
+		if err := mpdTransport(action); err != nil {
+			return "", err
+		}
+	} else if err := callPlayerMethod(ctx, info.BusName, spec.Method); err != nil {
+		return "", err
+	}
+	return awaitPlaybackStatus(ctx, info.BusName, spec.Want), nil
+}
+
+// mpdTransport maps explicit actions to MPD: pause 1 / pause 0 (or play when
+// stopped) / stop.
+func mpdTransport(action string) error {
+	c, err := mpd.Dial("tcp", mpdAddr)
+	if err != nil {
+		return fmt.Errorf("mpd dial: %w", err)
+	}
+	defer c.Close()
+
+	switch action {
+	case "play":
+		status, err := c.Status()
+		if err != nil {
+			return fmt.Errorf("mpd status: %w", err)
+		}
+		if status["state"] == "pause" {
+			return c.Pause(false)
+		}
+		if status["state"] == "stop" {
+			return c.Play(-1)
+		}
+		return nil
+	case "pause":
+		return c.Pause(true)
+	case "stop":
+		return c.Stop()
+	}
+	return fmt.Errorf("unknown mpd action %q", action)
+}
+
+// awaitPlaybackStatus polls until the player reports want or the settle
+// timeout passes, and returns the last status seen ("" if unreadable).
+func awaitPlaybackStatus(ctx context.Context, busName, want string) string {
+	deadline := time.Now().Add(transportSettleTimeout)
+	var status string
+	for {
+		status = readPlaybackStatus(ctx, busName)
+		if strings.EqualFold(status, want) || time.Now().After(deadline) || ctx.Err() != nil {
+			return status
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func readPlaybackStatus(ctx context.Context, busName string) string {
+	if busName == "mpd" {
+		c, err := mpd.Dial("tcp", mpdAddr)
+		if err != nil {
+			return ""
+		}
+		defer c.Close()
+		status, err := c.Status()
+		if err != nil {
+			return ""
+		}
+		return mpdToPlayerInfo(status, mpd.Attrs{}).PlaybackStatus
+	}
+	conn, err := sessionBus()
+	if err != nil {
+		return ""
+	}
+	v, err := conn.Object(busName, "/org/mpris/MediaPlayer2").GetProperty("org.mpris.MediaPlayer2.Player.PlaybackStatus")
+	if err != nil {
+		return ""
+	}
+	return asString(v)
+}
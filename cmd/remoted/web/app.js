@@ -34,6 +34,8 @@ let currentPlayer    = "";
 let lastPositionMs   = 0;
 let durationMs       = 0;
 let lastUpdateTs     = 0;
+let playbackRate     = 1;
+let currentTrackId   = "";
 let isPlaying        = false;
 let userScrubbing    = false;
 let foregroundRefreshInFlight = false;
@@ -55,12 +57,14 @@ document.querySelectorAll(".tab-btn").forEach((btn) => {
 });
 
 // ── Art & background ───────────────────────────────────────
-function setArtImage(src) {
+function setArtImage(src, backdrop) {
   const next = src || fallbackArt;
-  if (artImg.dataset.current === next) return;
+  const bg   = backdrop || (next !== fallbackArt ? next : "");
+  if (artImg.dataset.current === next && bgArt.dataset.current === bg) return;
   artImg.dataset.current = next;
+  bgArt.dataset.current  = bg;
   artImg.src = next;
-  bgArt.style.backgroundImage = next !== fallbackArt ? `url(${next})` : "";
+  bgArt.style.backgroundImage = bg ? `url(${bg})` : "";
 }
 
 artImg.onerror = () => {
@@ -69,6 +73,24 @@ artImg.onerror = () => {
   artImg.dataset.current = fallbackArt;
 };
 
+// Server-computed palette (see playerInfo.palette); cleared when art has none.
+function applyPalette(palette) {
+  const root = document.documentElement.style;
+  if (!palette) {
+    root.removeProperty("--art-dominant");
+    root.removeProperty("--art-vibrant");
+    root.removeProperty("--art-muted");
+    root.removeProperty("--art-foreground");
+    root.removeProperty("--icon-invert");
+    return;
+  }
+  root.setProperty("--art-dominant",   palette.dominant);
+  root.setProperty("--art-vibrant",    palette.vibrant);
+  root.setProperty("--art-muted",      palette.muted);
+  root.setProperty("--art-foreground", palette.foreground);
+  root.setProperty("--icon-invert", palette.foreground === "#000000" ? "1" : "0");
+}
+
 // ── Connection dot ─────────────────────────────────────────
 function setConnectionDot(connected) {
   connectionDot.classList.toggle("connected", connected);
@@ -89,7 +111,7 @@ function setPlayPauseIcon(status) {
 // ── Time & scrubber ────────────────────────────────────────
 function currentPositionMillis() {
   if (!isPlaying || durationMs <= 0) return lastPositionMs;
-  const elapsed = performance.now() - lastUpdateTs;
+  const elapsed = (performance.now() - lastUpdateTs) * playbackRate;
   return Math.min(durationMs, lastPositionMs + elapsed);
 }
 
@@ -108,10 +130,29 @@ function renderTime(posMs, durMs) {
 }
 
 function updateScrubber(info) {
+  currentTrackId = info.track_id || "";
+  const live = !!info.is_live;
+  replay10Btn.classList.toggle("live-hidden", live);
+  forward10Btn.classList.toggle("live-hidden", live);
+  totalTimeEl.classList.toggle("live-badge", live);
+  if (live) {
+    durationMs = 0;
+    lastPositionMs = 0;
+    positionSlider.max = 0;
+    positionSlider.disabled = true;
+    progressFill.style.width = "0%";
+    currentTimeEl.textContent = "";
+    totalTimeEl.textContent = "LIVE";
+    return;
+  }
   durationMs    = info.length_millis || 0;
-  lastPositionMs = info.position_millis || 0;
-  lastUpdateTs  = performance.now();
   isPlaying     = (info.playback_status || "").toLowerCase() === "playing";
+  playbackRate  = info.rate > 0 ? info.rate : 1;
+  // Account for the time between the server reading the position and now.
+  const age = info.position_updated_at ? Math.max(0, Date.now() - info.position_updated_at) : 0;
+  lastPositionMs = (info.position_millis || 0) + (isPlaying ? age * playbackRate : 0);
+  if (durationMs > 0) lastPositionMs = Math.min(durationMs, lastPositionMs);
+  lastUpdateTs  = performance.now();
   positionSlider.max = durationMs;
   positionSlider.disabled = durationMs === 0;
   if (!userScrubbing) positionSlider.value = lastPositionMs;
@@ -120,7 +161,7 @@ function updateScrubber(info) {
 
 function tick() {
   if (!userScrubbing && isPlaying && durationMs > 0) {
-    const elapsed  = performance.now() - lastUpdateTs;
+    const elapsed  = (performance.now() - lastUpdateTs) * playbackRate;
     const projected = Math.min(durationMs, lastPositionMs + elapsed);
     positionSlider.value = projected;
     renderTime(projected, durationMs);
@@ -137,52 +178,43 @@ function applyLocalSeek(deltaMs) {
 }
 
 // ── UI update ──────────────────────────────────────────────
+// Artwork first, then the matched service's icon (service rules live server-side).
 function pickArt(info) {
-  if (isNetflix(info))     return "/static/netflix_icon.svg";
-  if (isCrunchyroll(info)) {
-    if (info.art_url_proxy) return info.art_url_proxy;
-    if (info.art_url)       return info.art_url;
-    return "/static/crunchyroll_icon.svg";
-  }
-  const thumb = youtubeThumbFromURL(info.url || "");
-  if (thumb)               return thumb;
-  if (info.art_url_proxy)  return info.art_url_proxy;
-  if (info.art_url)        return info.art_url;
+  if (info.art_url_proxy) return info.art_url_proxy;
+  if (info.art_url)       return info.art_url;
+  if (info.service_icon)  return info.service_icon;
   return fallbackArt;
 }
 
-function isNetflix(info) {
-  const t  = (info.title    || "").toLowerCase();
-  const id = (info.identity || "").toLowerCase();
-  return t === "netflix" || id === "netflix";
-}
-
-function isCrunchyroll(info) {
-  return (info.title || "").toLowerCase().endsWith(" - watch on crunchyroll");
+// Service theme colors stand in for an art palette until one is known.
+function themePalette(theme) {
+  if (!theme || !theme.background) return null;
+  return {
+    dominant:   theme.background,
+    vibrant:    theme.accent || theme.background,
+    muted:      theme.background,
+    foreground: "#ffffff",
+  };
 }
 
-function youtubeThumbFromURL(url) {
-  if (!url) return "";
-  try {
-    const u = new URL(url);
-    if (!/^(www\.)?youtube\.com$/.test(u.hostname) && u.hostname !== "youtu.be") return "";
-    let id = u.hostname === "youtu.be"
-      ? u.pathname.slice(1)
-      : (u.searchParams.get("v") || "");
-    if (!id) return "";
-    return `https://i.ytimg.com/vi/${id}/hqdefault.jpg`;
-  } catch (e) {
-    return "";
-  }
+// "S1 E3" style label when the server matched an episode.
+function episodeLabel(info) {
+  if (!info.episode) return "";
+  return info.season ? `S${info.season} E${info.episode}` : `E${info.episode}`;
 }
 
 function updateUI(info) {
-  titleEl.textContent = info.title || "—";
-  const sub = [info.artist, info.identity].filter(Boolean).join(" · ");
-  artistEl.textContent = sub;
+  if (info.show) {
+    titleEl.textContent = info.episode_title || (info.episode ? `Episode ${info.episode}` : info.title) || "—";
+    artistEl.textContent = [info.show, episodeLabel(info), info.identity].filter(Boolean).join(" · ");
+  } else {
+    titleEl.textContent = info.title || "—";
+    artistEl.textContent = [info.artist, info.identity].filter(Boolean).join(" · ");
+  }
   setPlayPauseIcon(info.playback_status);
   updateScrubber(info);
-  setArtImage(pickArt(info));
+  setArtImage(pickArt(info), info.backdrop_url);
+  applyPalette(info.palette || themePalette(info.service_theme));
 }
 
 // ── Prefs ──────────────────────────────────────────────────
@@ -264,11 +296,16 @@ async function loadPlayers() {
     playerSelect.appendChild(autoOpt);
     for (const p of players) {
       const opt = document.createElement("option");
-      opt.value = p.bus_name;
-      opt.textContent = `${p.identity || p.bus_name} (${p.playback_status})`;
+      // The id survives browser restarts; the bus name may not.
+      opt.value = p.id || p.bus_name;
+      opt.textContent = `${p.alias || p.identity || p.bus_name} (${p.playback_status})`;
       playerSelect.appendChild(opt);
     }
-    if (selected && !players.find((p) => p.bus_name === selected)) selected = "";
+    if (selected) {
+      // Saved selections from before ids existed are bus names.
+      const match = players.find((p) => p.id === selected || p.bus_name === selected);
+      selected = match ? (match.id || match.bus_name) : "";
+    }
     if (selected) {
       playerSelect.value = selected;
       setCurrentPlayer(selected);
@@ -310,7 +347,7 @@ function startWS() {
       if (!wsReady) return;
       try {
         const data = JSON.parse(evt.data);
-        if (!data.error) updateUI(data);
+        if (!data.error && !data.event) updateUI(data);
       } catch (e) {
         console.error("WS parse error:", e);
       }
@@ -393,7 +430,10 @@ async function bindControls() {
     userScrubbing = false;
     if (durationMs === 0) return;
     try {
-      await postJSON("/player/seek", { target_ms: val, delta_ms: Math.round(delta) }, playerParam());
+      // Guarded so a seek from a stale view can't land on the next track.
+      const body = { target_ms: val, delta_ms: Math.round(delta) };
+      if (currentTrackId) body.if_track_id = currentTrackId;
+      await postJSON("/player/seek", body, playerParam());
       lastPositionMs = val;
       lastUpdateTs   = performance.now();
     } catch (err) { console.error("Seek failed:", err); }
@@ -411,6 +451,23 @@ playerSelect.addEventListener("change", () => {
   startWS();
 });
 
+// ── Share target ───────────────────────────────────────────
+// The manifest's share_target opens /ui?share_url=…; apps often put the link
+// in share_text instead, so take the first URL found in either.
+async function handleShare() {
+  const params = new URLSearchParams(location.search);
+  const shared = [params.get("share_url"), params.get("share_text")]
+    .filter(Boolean).join(" ");
+  if (!shared) return;
+  history.replaceState(null, "", location.pathname);
+  const match = shared.match(/https?:\/\/\S+/);
+  if (!match) return;
+  // Anyone can craft a share link, so never open it without asking.
+  if (!confirm(`Open this link on the desktop?\n\n${match[0]}`)) return;
+  try { await postJSON("/player/open", { uri: match[0] }, {}); }
+  catch (err) { console.error("Open shared link failed:", err); }
+}
+
 // ── Init ──────────────────────────────────────────────────
 async function init() {
   loadPrefs();
@@ -425,6 +482,7 @@ async function init() {
     await loadNowPlaying();
     await syncVolume();
   };
+  await handleShare();
   await foregroundRefresh();
   document.addEventListener("visibilitychange", () => {
     if (document.visibilityState === "visible") {
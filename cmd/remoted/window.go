@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Window management through the root org.mpris.MediaPlayer2 interface:
+// Raise, Quit and the Fullscreen property. Each is only attempted when the
+// player advertises the matching capability (see playerInfo.Can*).
+
+const mprisRootIface = "org.mpris.MediaPlayer2"
+
+type fullscreenRequest struct {
+	Fullscreen *bool `json:"fullscreen,omitempty"`
+}
+
+// populateRootProps fills DesktopEntry and the window capability flags from
+// one GetAll on the root interface.
+func populateRootProps(ctx context.Context, info *playerInfo, obj dbus.BusObject) {
+	props, err := getAllProps(ctx, obj, mprisRootIface)
+	if err != nil {
+		return
+	}
+	info.DesktopEntry = asString(props["DesktopEntry"])
+	info.CanRaise = asBool(props["CanRaise"])
+	info.CanQuit = asBool(props["CanQuit"])
+	info.CanSetFullscreen = asBool(props["CanSetFullscreen"])
+	info.Fullscreen = asBool(props["Fullscreen"])
+}
+
+func raiseHandler(w http.ResponseWriter, r *http.Request) {
+	windowHandler(w, r, "raise", func(info playerInfo) bool { return info.CanRaise })
+}
+
+func quitHandler(w http.ResponseWriter, r *http.Request) {
+	windowHandler(w, r, "quit", func(info playerInfo) bool { return info.CanQuit })
+}
+
+func windowHandler(w http.ResponseWriter, r *http.Request, action string, allowed func(playerInfo) bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
+	if !allowed(info) {
+		http.Error(w, fmt.Sprintf("%s does not support %s", info.Identity, action), http.StatusConflict)
+		return
+	}
+
+	method := mprisRootIface + ".Raise"
+	if action == "quit" {
+		method = mprisRootIface + ".Quit"
+	}
+	if err := callPlayerMethod(ctx, info.BusName, method); err != nil {
+		http.Error(w, fmt.Sprintf("call %s: %v", method, err), http.StatusInternalServerError)
+		return
+	}
+
+	if action == "raise" {
+		setLastPlayer(info.BusName)
+	}
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"player": info.Identity,
+		"action": action,
+		"status": "ok",
+	})
+}
+
+// fullscreenHandler sets the Fullscreen property; with no body (or no
+// "fullscreen" key) it toggles.
+func fullscreenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req fullscreenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	info, err := pickPlayer(ctx, r.URL.Query().Get("player"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("select player: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !requirePreconditions(w, preconditionsFrom(r), info) {
+		return
+	}
+	if !info.CanSetFullscreen {
+		http.Error(w, fmt.Sprintf("%s does not support fullscreen", info.Identity), http.StatusConflict)
+		return
+	}
+	want := !info.Fullscreen
+	if req.Fullscreen != nil {
+		want = *req.Fullscreen
+	}
+	if err := setPlayerFullscreen(info.BusName, want); err != nil {
+		http.Error(w, fmt.Sprintf("set fullscreen: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setLastPlayer(info.BusName)
+	if globalHub != nil {
+		globalHub.requestBroadcast()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"player":     info.Identity,
+		"action":     "fullscreen",
+		"fullscreen": want,
+		"status":     "ok",
+	})
+}
+
+func setPlayerFullscreen(busName string, on bool) error {
+	conn, err := sessionBus()
+	if err != nil {
+		return fmt.Errorf("session bus: %w", err)
+	}
+
+	obj := conn.Object(busName, "/org/mpris/MediaPlayer2")
+	return obj.SetProperty(mprisRootIface+".Fullscreen", dbus.MakeVariant(on))
+}
@@ -3,11 +3,9 @@ module github.com/ozdotdotdot/UMR
 go 1.21
 
 require (
+	github.com/fhs/gompd/v2 v2.3.0
 	github.com/godbus/dbus/v5 v5.2.0
 	nhooyr.io/websocket v1.8.17
 )
 
-require (
-	github.com/fhs/gompd/v2 v2.3.0 // indirect
-	golang.org/x/sys v0.27.0 // indirect
-)
+require golang.org/x/sys v0.27.0 // indirect